@@ -0,0 +1,255 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Instructions is a sequence of encoded instructions, each one an opcode followed by its operands
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			_, _ = fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		_, _ = fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	// Constants and literals
+	OpConstant Opcode = iota // push constants[operand]
+	OpTrue
+	OpFalse
+	OpNull
+	OpArray // pop operand elements, push an array of them
+	OpHash  // pop operand keys and values, push a hash of them
+
+	// Strings
+	OpInterpolate // pop operand parts of an interpolated string, push the string joining them
+
+	// Stack manipulation
+	OpPop
+
+	// Operators, each one pops its operands and pushes the result
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpPow
+	OpMod
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpGreaterThan
+	OpLessEqual
+	OpGreaterEqual
+	OpMinus
+	OpBang
+	OpBitNot
+
+	// Indexing
+	OpIndex    // pop index and container, push the element
+	OpSlice    // pop step, end, start and container, push the slice of the container
+	OpSetIndex // pop value, index and container, store and push the value; operand is the operator of a compound assignment or 0
+
+	// Control flow
+	OpJump               // jump to operand
+	OpJumpNotTruthy      // pop the condition, jump to operand if it is not truthy
+	OpJumpTruthyOrPop    // jump to operand if the value on top of the stack is truthy, otherwise pop it
+	OpJumpNotTruthyOrPop // jump to operand if the value on top of the stack is not truthy, otherwise pop it
+	OpIterInit           // pop an iterable, push an iterator over it
+	OpIterNext           // push the next element of the iterator on top of the stack or jump to operand if it is exhausted
+	OpDefaultMissing     // jump to operand 2 unless local operand 1 was not passed to the function
+
+	// Variables
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetOuter   // get the variable operand 2 of the scope operand 1 levels up
+	OpSetOuter   // set the variable operand 2 of the scope operand 1 levels up
+	OpGetName    // get the global or builtin named constants[operand], resolved at runtime
+	OpSetName    // set the global named constants[operand], resolved at runtime
+	OpEnterScope // start a scope with the variables described by constants[operand], e.g. for an iteration of a loop
+	OpLeaveScope // return to the scope around the current one
+
+	// Functions
+	OpClosure     // push a closure of the function constants[operand] over the current scope
+	OpCall        // call the function below operand arguments
+	OpReturnValue // return the value on top of the stack
+	OpReturn      // return null
+)
+
+// Definition describes the name of an opcode and the width in bytes of each of its operands
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpNull:     {"OpNull", []int{}},
+	OpArray:    {"OpArray", []int{2}},
+	OpHash:     {"OpHash", []int{2}},
+
+	OpInterpolate: {"OpInterpolate", []int{2}},
+
+	OpPop: {"OpPop", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpPow:          {"OpPow", []int{}},
+	OpMod:          {"OpMod", []int{}},
+	OpBitAnd:       {"OpBitAnd", []int{}},
+	OpBitOr:        {"OpBitOr", []int{}},
+	OpBitXor:       {"OpBitXor", []int{}},
+	OpShiftLeft:    {"OpShiftLeft", []int{}},
+	OpShiftRight:   {"OpShiftRight", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpMinus:        {"OpMinus", []int{}},
+	OpBang:         {"OpBang", []int{}},
+	OpBitNot:       {"OpBitNot", []int{}},
+
+	OpIndex:    {"OpIndex", []int{}},
+	OpSetIndex: {"OpSetIndex", []int{1}},
+	OpSlice:    {"OpSlice", []int{}},
+
+	OpJump:               {"OpJump", []int{2}},
+	OpJumpNotTruthy:      {"OpJumpNotTruthy", []int{2}},
+	OpJumpTruthyOrPop:    {"OpJumpTruthyOrPop", []int{2}},
+	OpJumpNotTruthyOrPop: {"OpJumpNotTruthyOrPop", []int{2}},
+	OpIterInit:           {"OpIterInit", []int{}},
+	OpIterNext:           {"OpIterNext", []int{2}},
+	OpDefaultMissing:     {"OpDefaultMissing", []int{1, 2}},
+
+	OpGetGlobal:  {"OpGetGlobal", []int{2}},
+	OpSetGlobal:  {"OpSetGlobal", []int{2}},
+	OpGetLocal:   {"OpGetLocal", []int{1}},
+	OpSetLocal:   {"OpSetLocal", []int{1}},
+	OpGetOuter:   {"OpGetOuter", []int{1, 1}},
+	OpSetOuter:   {"OpSetOuter", []int{1, 1}},
+	OpGetName:    {"OpGetName", []int{2}},
+	OpSetName:    {"OpSetName", []int{2}},
+	OpEnterScope: {"OpEnterScope", []int{2}},
+	OpLeaveScope: {"OpLeaveScope", []int{}},
+
+	OpClosure:     {"OpClosure", []int{2}},
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Make encodes an instruction, operands are stored big-endian
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction and returns them with the number of bytes read
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 { return uint8(ins[0]) }
@@ -0,0 +1,781 @@
+package compiler
+
+import (
+	"fmt"
+	"waixg/code"
+	"waixg/interpreter/ast"
+	"waixg/interpreter/object"
+	"waixg/interpreter/token"
+)
+
+const (
+	// MaxGlobals is the number of globals that the 2-byte operands of OpGetGlobal and OpSetGlobal can address
+	MaxGlobals = 1 << 16
+	// MaxLocals is the number of locals of a function that the 1-byte operands of OpGetLocal and OpSetLocal can address
+	MaxLocals = 1 << 8
+	// MaxJumpTarget is the largest offset of an instruction that the 2-byte operands of jumps can address
+	MaxJumpTarget = 1<<16 - 1
+)
+
+// infixOperators maps the operators of infix expressions to their opcodes
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"^":  code.OpPow,
+	"%":  code.OpMod,
+	"&":  code.OpBitAnd,
+	"|":  code.OpBitOr,
+	"~":  code.OpBitXor,
+	"<<": code.OpShiftLeft,
+	">>": code.OpShiftRight,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLessThan,
+	">":  code.OpGreaterThan,
+	"<=": code.OpLessEqual,
+	">=": code.OpGreaterEqual,
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// loop keeps track of the jumps of `break` and `continue` inside a loop, to patch once their targets are known
+type loop struct {
+	breaks    []int
+	continues []int
+}
+
+// CompilationScope holds the instructions of the function currently being compiled
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	sourceMap           map[int]token.Position
+	loops               []*loop
+}
+
+type Compiler struct {
+	constants []object.Object
+	names     map[string]int // constant index of each name used by OpGetName and OpSetName
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+}
+
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), []object.Object{})
+}
+
+// NewWithState creates a compiler continuing with the globals and constants of a previous compilation,
+// e.g. for the next line in a REPL.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	mainScope := CompilationScope{
+		instructions: code.Instructions{},
+		sourceMap:    make(map[int]token.Position),
+	}
+
+	return &Compiler{
+		constants:   constants,
+		names:       make(map[string]int),
+		symbolTable: s,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+// Bytecode is the result of a compilation, ready to be run by the virtual machine
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	SourceMap    map[int]token.Position
+	GlobalNames  []string // names of the globals, indexed like the globals themselves
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+		GlobalNames:  c.symbolTable.Names(),
+	}
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	// Statements
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		// a function is bound before it is compiled, so it can call itself recursively
+		_, isFunction := node.Value.(*ast.FunctionLiteral)
+
+		var symbol Symbol
+		var err error
+		if isFunction {
+			if symbol, err = c.define(node, node.Name.Value); err != nil {
+				return err
+			}
+		}
+
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		if !isFunction {
+			if symbol, err = c.define(node, node.Name.Value); err != nil {
+				return err
+			}
+		}
+		c.emitSet(node, symbol)
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+
+	case *ast.BreakStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return newError(node, "break outside of loop")
+		}
+		l.breaks = append(l.breaks, c.emit(code.OpJump, 9999))
+
+	case *ast.ContinueStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return newError(node, "continue outside of loop")
+		}
+		l.continues = append(l.continues, c.emit(code.OpJump, 9999))
+
+	// Expressions
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emitAt(node, code.OpBang)
+		case "-":
+			c.emitAt(node, code.OpMinus)
+		case "~":
+			c.emitAt(node, code.OpBitNot)
+		default:
+			return newError(node, "unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return newError(node, "unknown operator %s", node.Operator)
+		}
+
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emitAt(node, op)
+
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+
+	case *ast.Identifier:
+		c.emitGet(node, node.Value)
+
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		// the pairs are evaluated and added to the hash in source order
+		for _, k := range node.Keys {
+			if err := c.Compile(k); err != nil {
+				return err
+			}
+			if err := c.Compile(node.Pairs[k]); err != nil {
+				return err
+			}
+		}
+		c.emitAt(node, code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emitAt(node, code.OpIndex)
+
+	case *ast.SliceExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		// the parts that are left out are null
+		for _, exp := range []ast.Expression{node.Start, node.End, node.Step} {
+			if exp == nil {
+				c.emit(code.OpNull)
+			} else if err := c.Compile(exp); err != nil {
+				return err
+			}
+		}
+		c.emitAt(node, code.OpSlice)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+
+	case *ast.CallExpression:
+		if len(node.Arguments) > 255 {
+			return newError(node, "too many arguments: %d", len(node.Arguments))
+		}
+
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emitAt(node, code.OpCall, len(node.Arguments))
+
+	default:
+		return newError(node, "cannot compile %T", node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// the jump target is patched once the consequence is compiled
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockExpression(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlockExpression(node.Alternative); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	return c.checkJumpTarget(node, len(c.currentInstructions()))
+}
+
+// compileLogicalExpression compiles && and || so that the right operand is only evaluated if the left one
+// doesn't decide the result, which is the left operand then
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	op := code.OpJumpNotTruthyOrPop
+	if node.Operator == "||" {
+		op = code.OpJumpTruthyOrPop
+	}
+	// the jump target is patched once the right operand is compiled
+	jumpPos := c.emit(op, 9999)
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	return c.checkJumpTarget(node, len(c.currentInstructions()))
+}
+
+// compileBlockExpression compiles a block that leaves its value on the stack:
+// the value of its last expression statement, or null.
+func (c *Compiler) compileBlockExpression(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	start := len(c.currentInstructions())
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	l := c.enterLoop()
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+	c.leaveLoop()
+
+	end := len(c.currentInstructions())
+	if err := c.checkJumpTarget(node, end); err != nil {
+		return err
+	}
+	c.changeOperand(jumpNotTruthyPos, end)
+	c.patchLoop(l, start, end)
+
+	// like in the evaluator, a loop evaluates to null
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+
+	return nil
+}
+
+// compileForStatement compiles a for-in loop. The iterator stays on the stack while the loop runs.
+// Like in the evaluator, every iteration has its own scope holding the loop variable and the variables of the body,
+// so closures created in different iterations don't share them.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emitAt(node, code.OpIterInit)
+
+	next := c.emit(code.OpIterNext, 9999)
+
+	// the names of the variables are only known once the body is compiled
+	scope := &object.CompiledScope{}
+	c.emit(code.OpEnterScope, c.addConstant(scope))
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+
+	symbol, err := c.define(node, node.Variable.Value)
+	if err != nil {
+		return err
+	}
+	c.emitSet(node, symbol)
+
+	l := c.enterLoop()
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.leaveLoop()
+
+	scope.LocalNames = c.symbolTable.Names()
+	c.symbolTable = c.symbolTable.Outer
+
+	// continue and break leave the scope of the iteration as well
+	continueTarget := c.emit(code.OpLeaveScope)
+	c.emit(code.OpJump, next)
+	breakTarget := c.emit(code.OpLeaveScope)
+
+	end := len(c.currentInstructions())
+	if err := c.checkJumpTarget(node, end); err != nil {
+		return err
+	}
+	c.changeOperand(next, end)
+	c.patchLoop(l, continueTarget, breakTarget)
+
+	// remove the iterator, the loop itself evaluates to null
+	c.emit(code.OpPop)
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+
+	return nil
+}
+
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	var compoundOp code.Opcode
+	if node.Operator != "=" {
+		op, ok := infixOperators[node.Operator[:len(node.Operator)-1]]
+		if !ok {
+			return newError(node, "unknown operator %s", node.Operator)
+		}
+		compoundOp = op
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		if node.Operator != "=" {
+			c.emitGet(target, target.Value)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if node.Operator != "=" {
+			c.emitAt(node, compoundOp)
+		}
+
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok {
+			c.emitAt(node, code.OpSetName, c.addName(target.Value))
+		} else {
+			c.emitSet(node, symbol)
+		}
+
+		// an assignment evaluates to the assigned value
+		c.emitGet(target, target.Value)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emitAt(node, code.OpSetIndex, int(compoundOp))
+
+	default:
+		return newError(node, "cannot assign to %s", node.Target.String())
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	c.enterScope()
+
+	for _, p := range node.Parameters {
+		if _, err := c.define(p, p.Value); err != nil {
+			return err
+		}
+	}
+	if node.Rest != nil {
+		if _, err := c.define(node.Rest, node.Rest.Value); err != nil {
+			return err
+		}
+	}
+
+	// fill in the default value of every parameter without an argument
+	for i, p := range node.Parameters {
+		def, ok := node.Defaults[p.Value]
+		if !ok {
+			continue
+		}
+
+		jumpPos := c.emit(code.OpDefaultMissing, i, 9999)
+		if err := c.Compile(def); err != nil {
+			return err
+		}
+		c.emit(code.OpSetLocal, i)
+		if err := c.checkJumpTarget(def, len(c.currentInstructions())); err != nil {
+			return err
+		}
+		c.changeOperand(jumpPos, i, len(c.currentInstructions()))
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	// the value of the last expression statement is returned implicitly
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	numLocals := c.symbolTable.numDefinitions
+	localNames := c.symbolTable.Names()
+	sourceMap := c.scopes[c.scopeIndex].sourceMap
+	instructions := c.leaveScope()
+
+	compiledFn := &object.CompiledFunction{
+		Name:          node.Name,
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		NumDefaults:   len(node.Defaults),
+		HasRest:       node.Rest != nil,
+		LocalNames:    localNames,
+		SourceMap:     sourceMap,
+		Literal:       node,
+	}
+
+	c.emit(code.OpClosure, c.addConstant(compiledFn))
+
+	return nil
+}
+
+// define creates a variable in the current symbol table,
+// failing if its index doesn't fit in the operands of the instructions accessing it
+func (c *Compiler) define(node ast.Node, name string) (Symbol, error) {
+	symbol := c.symbolTable.Define(name)
+
+	if symbol.Scope == GlobalScope && symbol.Index >= MaxGlobals {
+		return symbol, newError(node, "too many global variables: more than %d", MaxGlobals)
+	}
+	if symbol.Scope == LocalScope && symbol.Index >= MaxLocals {
+		return symbol, newError(node, "too many local variables: more than %d", MaxLocals)
+	}
+
+	return symbol, nil
+}
+
+func (c *Compiler) emitGet(node ast.Node, name string) {
+	symbol, ok := c.symbolTable.Resolve(name)
+	if !ok {
+		// unknown names are globals defined later or builtins, they are resolved when the code runs
+		c.emitAt(node, code.OpGetName, c.addName(name))
+		return
+	}
+
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emitAt(node, code.OpGetGlobal, symbol.Index)
+	case LocalScope:
+		c.emitAt(node, code.OpGetLocal, symbol.Index)
+	case OuterScope:
+		c.emitAt(node, code.OpGetOuter, symbol.Depth, symbol.Index)
+	}
+}
+
+func (c *Compiler) emitSet(node ast.Node, symbol Symbol) {
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emitAt(node, code.OpSetGlobal, symbol.Index)
+	case LocalScope:
+		c.emitAt(node, code.OpSetLocal, symbol.Index)
+	case OuterScope:
+		c.emitAt(node, code.OpSetOuter, symbol.Depth, symbol.Index)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// addName returns the index of a string constant holding name, each name is only added once
+func (c *Compiler) addName(name string) int {
+	if idx, ok := c.names[name]; ok {
+		return idx
+	}
+
+	idx := c.addConstant(&object.String{Value: name})
+	c.names[name] = idx
+	return idx
+}
+
+// emit appends an instruction and returns its position
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+// emitAt emits an instruction which can fail at runtime and remembers the position of node for it,
+// so the virtual machine can report where the error happened.
+func (c *Compiler) emitAt(node ast.Node, op code.Opcode, operands ...int) int {
+	pos := c.emit(op, operands...)
+	c.scopes[c.scopeIndex].sourceMap[pos] = node.Pos()
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	c.scopes[c.scopeIndex].instructions = old[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+// checkJumpTarget returns an error if target, the farthest target of the jumps compiled for node,
+// doesn't fit into the operands of the jumps
+func (c *Compiler) checkJumpTarget(node ast.Node, target int) error {
+	if target > MaxJumpTarget {
+		return newError(node, "too much code: jump target %d is beyond %d", target, MaxJumpTarget)
+	}
+	return nil
+}
+
+// changeOperand replaces the operands of the instruction at pos, used to patch jump targets
+func (c *Compiler) changeOperand(pos int, operands ...int) {
+	op := code.Opcode(c.currentInstructions()[pos])
+	newInstruction := code.Make(op, operands...)
+
+	c.replaceInstruction(pos, newInstruction)
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions: code.Instructions{},
+		sourceMap:    make(map[int]token.Position),
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+func (c *Compiler) enterLoop() *loop {
+	l := &loop{}
+	c.scopes[c.scopeIndex].loops = append(c.scopes[c.scopeIndex].loops, l)
+	return l
+}
+
+func (c *Compiler) leaveLoop() {
+	loops := c.scopes[c.scopeIndex].loops
+	c.scopes[c.scopeIndex].loops = loops[:len(loops)-1]
+}
+
+// patchLoop sets the targets of the jumps of the `continue` and `break` statements of a loop
+func (c *Compiler) patchLoop(l *loop, continueTarget, breakTarget int) {
+	for _, pos := range l.continues {
+		c.changeOperand(pos, continueTarget)
+	}
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, breakTarget)
+	}
+}
+
+// currentLoop returns the innermost loop of the current function, or nil outside of loops
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+	return loops[len(loops)-1]
+}
+
+// newError creates a compilation error pointing at node
+func newError(node ast.Node, format string, a ...interface{}) *object.Error {
+	return &object.Error{Err: fmt.Errorf(format, a...), Pos: node.Pos()}
+}
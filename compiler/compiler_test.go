@@ -0,0 +1,544 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"waixg/code"
+	"waixg/interpreter/lexer"
+	"waixg/interpreter/object"
+	"waixg/interpreter/parser"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for i, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("test %d: compiler error: %s", i, err)
+		}
+
+		bytecode := compiler.Bytecode()
+		testInstructions(t, i, tt.expectedInstructions, bytecode.Instructions)
+		testConstants(t, i, tt.expectedConstants, bytecode.Constants)
+	}
+}
+
+func concatInstructions(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func testInstructions(t *testing.T, id int, expected []code.Instructions, actual code.Instructions) {
+	concatted := concatInstructions(expected)
+	if concatted.String() != actual.String() {
+		t.Errorf("test %d: wrong instructions.\nwant=\n%s\ngot=\n%s", id, concatted, actual)
+	}
+}
+
+func testConstants(t *testing.T, id int, expected []interface{}, actual []object.Object) {
+	if len(expected) != len(actual) {
+		t.Errorf("test %d: wrong number of constants. got=%d, want=%d", id, len(actual), len(expected))
+		return
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				t.Errorf("test %d: constant %d is not Integer %d. got=%+v", id, i, constant, actual[i])
+			}
+		case string:
+			str, ok := actual[i].(*object.String)
+			if !ok || str.Value != constant {
+				t.Errorf("test %d: constant %d is not String %q. got=%+v", id, i, constant, actual[i])
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				t.Errorf("test %d: constant %d is not a CompiledFunction. got=%T", id, i, actual[i])
+				continue
+			}
+			testInstructions(t, id, constant, fn.Instructions)
+		case []string:
+			scope, ok := actual[i].(*object.CompiledScope)
+			if !ok {
+				t.Errorf("test %d: constant %d is not a CompiledScope. got=%T", id, i, actual[i])
+				continue
+			}
+			if strings.Join(scope.LocalNames, " ") != strings.Join(constant, " ") {
+				t.Errorf("test %d: constant %d has wrong local names. want=%v, got=%v", id, i, constant, scope.LocalNames)
+			}
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "-1 <= 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessEqual),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "~1 % 2 << 3",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpBitNot),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMod),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpShiftLeft),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 | 2 ~ 3 & 4 >> 5",
+			expectedConstants: []interface{}{1, 2, 3, 4, 5},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpShiftRight),
+				code.Make(code.OpBitAnd),
+				code.Make(code.OpBitXor),
+				code.Make(code.OpBitOr),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `"a${1}b"`,
+			expectedConstants: []interface{}{"a", 1, "b"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpInterpolate, 3),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "[1, 2][1:]",
+			expectedConstants: []interface{}{1, 2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpNull),
+				code.Make(code.OpNull),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `"abc"[:2:-1]`,
+			expectedConstants: []interface{}{"abc", 2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpMinus),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 10 }; 3333;",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "true && false; 1",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthyOrPop, 5),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpPop),
+				// 0006
+				code.Make(code.OpConstant, 0),
+				// 0009
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "false or 1 < 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpFalse),
+				// 0001
+				code.Make(code.OpJumpTruthyOrPop, 11),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpConstant, 1),
+				// 0010
+				code.Make(code.OpLessThan),
+				// 0011
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestVariables(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let one = 1; one = 2; two",
+			expectedConstants: []interface{}{1, 2, "two"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetName, 2),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(a, b = 5) { a + b }",
+			expectedConstants: []interface{}{
+				5,
+				[]code.Instructions{
+					code.Make(code.OpDefaultMissing, 1, 9),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn(a) { fn() { a = 1 } }",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetOuter, 1, 0),
+					code.Make(code.OpGetOuter, 1, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpClosure, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "while (true) { break; continue; }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 13),
+				// 0004
+				code.Make(code.OpJump, 13),
+				// 0007
+				code.Make(code.OpJump, 0),
+				// 0010
+				code.Make(code.OpJump, 0),
+				// 0013
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "for (x in [1]) { let y = x; if (x) { continue; } break; }",
+			expectedConstants: []interface{}{1, []string{"x", "y"}},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpIterInit),
+				// 0007
+				code.Make(code.OpIterNext, 41),
+				// 0010
+				code.Make(code.OpEnterScope, 1),
+				// 0013
+				code.Make(code.OpSetLocal, 0),
+				// 0015
+				code.Make(code.OpGetLocal, 0),
+				// 0017
+				code.Make(code.OpSetLocal, 1),
+				// 0019
+				code.Make(code.OpGetLocal, 0),
+				// 0021
+				code.Make(code.OpJumpNotTruthy, 31),
+				// 0024
+				code.Make(code.OpJump, 36),
+				// 0027
+				code.Make(code.OpNull),
+				// 0028
+				code.Make(code.OpJump, 32),
+				// 0031
+				code.Make(code.OpNull),
+				// 0032
+				code.Make(code.OpPop),
+				// 0033
+				code.Make(code.OpJump, 40),
+				// 0036
+				code.Make(code.OpLeaveScope),
+				// 0037
+				code.Make(code.OpJump, 7),
+				// 0040
+				code.Make(code.OpLeaveScope),
+				// 0041
+				code.Make(code.OpPop),
+				// 0042
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestCompilationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break outside of loop"},
+		{"fn() {\n  continue;\n}", "2:3: continue outside of loop"},
+	}
+
+	for i, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		err := New().Compile(program)
+		if err == nil {
+			t.Errorf("test %d: expected compilation error", i)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("test %d: wrong error. expected=%q, got=%q", i, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestVariableLimits(t *testing.T) {
+	// identifiers can't contain digits, so the variables are named xa, xb, ..., xz, xba, xbb, ...
+	name := func(i int) string {
+		n := string(rune('a' + i%26))
+		for i /= 26; i > 0; i /= 26 {
+			n = string(rune('a'+i%26)) + n
+		}
+		return "x" + n
+	}
+	lets := func(n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&b, "let %s = true;\n", name(i))
+		}
+		return b.String()
+	}
+	params := func(n int) string {
+		names := make([]string, n)
+		for i := range names {
+			names[i] = name(i)
+		}
+		return strings.Join(names, ", ")
+	}
+
+	tests := []struct {
+		input    string
+		expected string // empty if the input compiles
+	}{
+		{"fn() {\n" + lets(MaxLocals) + "}", ""},
+		{"fn() {\n" + lets(MaxLocals+1) + "}", "258:1: too many local variables: more than 256"},
+		{"fn(" + params(MaxLocals) + ") {}", ""},
+		{"fn(" + params(MaxLocals+1) + ") {}", "1:1258: too many local variables: more than 256"},
+		{"fn(" + params(MaxLocals) + ", ...rest) {}", "1:1261: too many local variables: more than 256"},
+		{"for (x in []) {\n" + lets(MaxLocals-1) + "}", ""},
+		{"for (x in []) {\n" + lets(MaxLocals) + "}", "257:1: too many local variables: more than 256"},
+		{lets(MaxGlobals), ""},
+		{lets(MaxGlobals + 1), "65537:1: too many global variables: more than 65536"},
+	}
+
+	for i, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("test %d: parser errors: %v", i, p.Errors())
+		}
+
+		err := New().Compile(program)
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("test %d: unexpected compilation error: %s", i, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("test %d: expected compilation error", i)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("test %d: wrong error. expected=%q, got=%q", i, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestJumpTargetLimits(t *testing.T) {
+	// every statement compiles to 4 bytes: OpConstant with its operand and OpPop
+	statements := func(n int) string {
+		return strings.Repeat("1;\n", n)
+	}
+	elements := func(n int) string {
+		return strings.TrimSuffix(strings.Repeat("1, ", n), ", ")
+	}
+
+	tests := []struct {
+		input    string
+		expected string // empty if the input compiles
+	}{
+		{statements(20000), ""},
+		{"if (true) {\n" + statements(16000) + "}", ""},
+		{"if (true) {\n" + statements(20000) + "}", "1:1: too much code: jump target 80007 is beyond 65535"},
+		{"if (true) { 1 } else {\n" + statements(20000) + "}", "1:1: too much code: jump target 80009 is beyond 65535"},
+		{statements(20000) + "if (true) { 1 }", "20001:1: too much code: jump target 80011 is beyond 65535"},
+		{"while (false) {\n" + statements(20000) + "}", "1:1: too much code: jump target 80007 is beyond 65535"},
+		{"for (x in []) {\n" + statements(20000) + "}", "1:1: too much code: jump target 80017 is beyond 65535"},
+		{"true && [" + elements(30000) + "]", "1:6: too much code: jump target 90007 is beyond 65535"},
+		{"fn(a = [" + elements(30000) + "]) { a }", "1:8: too much code: jump target 90009 is beyond 65535"},
+	}
+
+	for i, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("test %d: parser errors: %v", i, p.Errors())
+		}
+
+		err := New().Compile(program)
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("test %d: unexpected compiler error: %s", i, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("test %d: expected a compiler error", i)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("test %d: wrong error. expected=%q, got=%q", i, tt.expected, err.Error())
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+	OuterScope  SymbolScope = "OUTER" // local of an enclosing function or loop
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	Depth int // number of scopes to go up for an OuterScope symbol
+}
+
+// SymbolTable keeps track of the variables of a single function, of the body of a for loop, or of the global scope
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	return &SymbolTable{store: s}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define creates a variable in this table.
+// Like `let` in the evaluator, defining a name twice in the same table reuses the existing variable.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions, Scope: LocalScope}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	}
+
+	s.store[name] = symbol
+	s.numDefinitions++
+	return symbol
+}
+
+// Resolve looks up name in this table and then in the enclosing ones.
+// Locals of enclosing functions and loops are returned as OuterScope symbols with their distance in Depth.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	if symbol, ok := s.store[name]; ok {
+		return symbol, true
+	}
+
+	if s.Outer == nil {
+		return Symbol{}, false
+	}
+
+	symbol, ok := s.Outer.Resolve(name)
+	if !ok || symbol.Scope == GlobalScope {
+		return symbol, ok
+	}
+
+	symbol.Scope = OuterScope
+	symbol.Depth++
+	return symbol, true
+}
+
+// Names returns the names of the variables in this table, indexed like the variables themselves
+func (s *SymbolTable) Names() []string {
+	names := make([]string, s.numDefinitions)
+	for name, symbol := range s.store {
+		names[symbol.Index] = name
+	}
+	return names
+}
@@ -0,0 +1,49 @@
+package compiler
+
+import "testing"
+
+func TestDefineAndResolve(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	global.Define("b")
+
+	first := NewEnclosedSymbolTable(global)
+	first.Define("c")
+
+	second := NewEnclosedSymbolTable(first)
+	second.Define("d")
+
+	third := NewEnclosedSymbolTable(second)
+
+	if again := global.Define("a"); again != a {
+		t.Errorf("redefining a changed the symbol. got=%+v, want=%+v", again, a)
+	}
+
+	tests := []struct {
+		table    *SymbolTable
+		expected Symbol
+	}{
+		{global, Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{first, Symbol{Name: "b", Scope: GlobalScope, Index: 1}},
+		{first, Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+		{second, Symbol{Name: "c", Scope: OuterScope, Index: 0, Depth: 1}},
+		{second, Symbol{Name: "d", Scope: LocalScope, Index: 0}},
+		{third, Symbol{Name: "c", Scope: OuterScope, Index: 0, Depth: 2}},
+		{third, Symbol{Name: "d", Scope: OuterScope, Index: 0, Depth: 1}},
+	}
+
+	for i, tt := range tests {
+		result, ok := tt.table.Resolve(tt.expected.Name)
+		if !ok {
+			t.Errorf("test %d: name %s not resolvable", i, tt.expected.Name)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("test %d: expected %s to resolve to %+v, got=%+v", i, tt.expected.Name, tt.expected, result)
+		}
+	}
+
+	if _, ok := third.Resolve("e"); ok {
+		t.Errorf("name e resolved, but was never defined")
+	}
+}
@@ -0,0 +1,482 @@
+package waixg
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"waixg/evaluator"
+	"waixg/interpreter/object"
+)
+
+var (
+	objectType  = reflect.TypeOf((*object.Object)(nil)).Elem()
+	contextType = reflect.TypeOf((*object.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToObject converts a Go value to an object:
+//   - nil to null, bools to booleans
+//   - signed and unsigned integers to integers, floats to floats, strings to strings
+//   - slices and arrays to arrays, maps to hashes, pointers and interfaces to the value they point to;
+//     a value containing itself, like a map stored in itself, can't be converted
+//   - functions to builtin functions, converting their arguments like FromObject and their results
+//     like ToObject; a non-nil error as last result or a panic becomes a runtime error. A function whose
+//     first parameter is an object.Context gets the context of the call, e.g. to call functions passed to it.
+//
+// Objects are returned as they are.
+func ToObject(value interface{}) (object.Object, error) {
+	return toObject(value, "")
+}
+
+func toObject(value interface{}, name string) (object.Object, error) {
+	if obj, ok := value.(object.Object); ok {
+		return obj, nil
+	}
+
+	return valueToObject(reflect.ValueOf(value), name)
+}
+
+// valueToObject converts v, name is used in the errors of converted functions
+func valueToObject(v reflect.Value, name string) (object.Object, error) {
+	return convertValue(v, name, visiting{})
+}
+
+// visit identifies a map, slice or pointer being converted.
+// Like encoding/json, slices are told apart by their length too, as s[:1] may be an element of s.
+type visit struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+func visitOf(v reflect.Value) visit {
+	key := visit{typ: v.Type(), ptr: v.Pointer()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+	return key
+}
+
+// visiting holds the maps, slices and pointers on the path from the converted value to the current one
+type visiting map[visit]bool
+
+// enter marks v as being converted, failing if it already is, i.e. if v contains itself.
+// leave must be called once v is converted.
+func (seen visiting) enter(v reflect.Value) error {
+	key := visitOf(v)
+	if seen[key] {
+		return fmt.Errorf("cannot convert %s to an object: it contains itself", v.Type())
+	}
+	seen[key] = true
+	return nil
+}
+
+func (seen visiting) leave(v reflect.Value) {
+	delete(seen, visitOf(v))
+}
+
+// convertValue converts v, seen holds the values v is part of
+func convertValue(v reflect.Value, name string, seen visiting) (object.Object, error) {
+	if !v.IsValid() {
+		return evaluator.NULL, nil
+	}
+	if v.Type().Implements(objectType) && v.CanInterface() {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return v.Interface().(object.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("cannot convert %d to INTEGER", v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+
+	case reflect.Slice, reflect.Array:
+		// only slices share their elements, an array holding itself isn't possible
+		if v.Kind() == reflect.Slice && v.Len() > 0 {
+			if err := seen.enter(v); err != nil {
+				return nil, err
+			}
+			defer seen.leave(v)
+		}
+
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			el, err := convertValue(v.Index(i), name, seen)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return &object.Array{Elements: elements}, nil
+
+	case reflect.Map:
+		if v.Len() > 0 {
+			if err := seen.enter(v); err != nil {
+				return nil, err
+			}
+			defer seen.leave(v)
+		}
+
+		pairs := make([]object.HashPair, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := convertValue(iter.Key(), name, seen)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := key.(object.Hashable); !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			value, err := convertValue(iter.Value(), name, seen)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, object.HashPair{Key: key, Value: value})
+		}
+
+		// Go maps have no order, the keys are sorted so the hash is the same on every conversion
+		sort.Slice(pairs, func(i, j int) bool {
+			return lessKey(pairs[i].Key, pairs[j].Key)
+		})
+		hash := object.NewHash()
+		for _, pair := range pairs {
+			hash.Set(pair.Key.(object.Hashable).HashKey(), pair)
+		}
+		return hash, nil
+
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		if v.Kind() == reflect.Pointer {
+			if err := seen.enter(v); err != nil {
+				return nil, err
+			}
+			defer seen.leave(v)
+		}
+		return convertValue(v.Elem(), name, seen)
+
+	case reflect.Func:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return builtinFromFunc(v, name)
+
+	default:
+		return nil, fmt.Errorf("cannot convert %s to an object", v.Type())
+	}
+}
+
+// lessKey orders the keys of a hash converted from a Go map: by type, then by value
+func lessKey(a, b object.Object) bool {
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+
+	switch a := a.(type) {
+	case *object.Integer:
+		return a.Value < b.(*object.Integer).Value
+	case *object.String:
+		return a.Value < b.(*object.String).Value
+	case *object.Boolean:
+		return !a.Value && b.(*object.Boolean).Value
+	default:
+		return false
+	}
+}
+
+// FromObject converts an object to a Go value:
+//   - null to nil, booleans to bool, integers to int64, floats to float64, strings to string
+//   - arrays to []interface{}, hashes to map[interface{}]interface{}
+//   - errors to error
+//
+// Other objects, like functions, are returned as they are. An array or hash containing itself, which
+// index assignments allow, can't be converted.
+func FromObject(obj object.Object) (interface{}, error) {
+	return fromObject(obj, map[object.Object]bool{})
+}
+
+// fromObject converts obj, seen holds the arrays and hashes obj is part of
+func fromObject(obj object.Object, seen map[object.Object]bool) (interface{}, error) {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.Float:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Array:
+		if seen[obj] {
+			return nil, fmt.Errorf("cannot convert %s: it contains itself", obj.Type())
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		elements := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			value, err := fromObject(el, seen)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *object.Hash:
+		if seen[obj] {
+			return nil, fmt.Errorf("cannot convert %s: it contains itself", obj.Type())
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		values := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Ordered() {
+			key, err := fromObject(pair.Key, seen)
+			if err != nil {
+				return nil, err
+			}
+			value, err := fromObject(pair.Value, seen)
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+		return values, nil
+	case *object.Error:
+		return error(obj), nil
+	default:
+		return obj, nil
+	}
+}
+
+// objectToValue converts obj to a Go value of type typ, reporting false if it can't be converted
+func objectToValue(obj object.Object, typ reflect.Type) (reflect.Value, bool) {
+	if typ == objectType {
+		v := reflect.New(typ).Elem()
+		v.Set(reflect.ValueOf(obj))
+		return v, true
+	}
+
+	switch typ.Kind() {
+	case reflect.Interface:
+		value, err := FromObject(obj)
+		if err != nil {
+			return reflect.Value{}, false
+		}
+		if value == nil {
+			return reflect.Zero(typ), true
+		}
+		v := reflect.ValueOf(value)
+		if !v.Type().AssignableTo(typ) {
+			return reflect.Value{}, false
+		}
+		return v.Convert(typ), true
+
+	case reflect.Bool:
+		boolean, ok := obj.(*object.Boolean)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		return reflect.ValueOf(boolean.Value).Convert(typ), true
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		integer, ok := obj.(*object.Integer)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		v := reflect.New(typ).Elem()
+		if v.OverflowInt(integer.Value) {
+			return reflect.Value{}, false
+		}
+		v.SetInt(integer.Value)
+		return v, true
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		integer, ok := obj.(*object.Integer)
+		if !ok || integer.Value < 0 {
+			return reflect.Value{}, false
+		}
+		v := reflect.New(typ).Elem()
+		if v.OverflowUint(uint64(integer.Value)) {
+			return reflect.Value{}, false
+		}
+		v.SetUint(uint64(integer.Value))
+		return v, true
+
+	case reflect.Float32, reflect.Float64:
+		v := reflect.New(typ).Elem()
+		switch number := obj.(type) {
+		case *object.Float:
+			v.SetFloat(number.Value)
+		case *object.Integer:
+			v.SetFloat(float64(number.Value))
+		default:
+			return reflect.Value{}, false
+		}
+		return v, true
+
+	case reflect.String:
+		str, ok := obj.(*object.String)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		return reflect.ValueOf(str.Value).Convert(typ), true
+
+	case reflect.Slice:
+		array, ok := obj.(*object.Array)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		v := reflect.MakeSlice(typ, len(array.Elements), len(array.Elements))
+		for i, el := range array.Elements {
+			elValue, ok := objectToValue(el, typ.Elem())
+			if !ok {
+				return reflect.Value{}, false
+			}
+			v.Index(i).Set(elValue)
+		}
+		return v, true
+
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		v := reflect.MakeMapWithSize(typ, len(hash.Pairs))
+		for _, pair := range hash.Ordered() {
+			key, ok := objectToValue(pair.Key, typ.Key())
+			if !ok {
+				return reflect.Value{}, false
+			}
+			value, ok := objectToValue(pair.Value, typ.Elem())
+			if !ok {
+				return reflect.Value{}, false
+			}
+			v.SetMapIndex(key, value)
+		}
+		return v, true
+
+	default:
+		return reflect.Value{}, false
+	}
+}
+
+// builtinFromFunc wraps the Go function fn into a builtin function.
+// fn may return nothing, a value, an error, or a value and an error.
+func builtinFromFunc(fn reflect.Value, name string) (object.Object, error) {
+	if name == "" {
+		name = "builtin function"
+	}
+
+	switch builtin := fn.Interface().(type) {
+	case func(ctx object.Context, args ...object.Object) object.Object:
+		return &object.Builtin{Fn: recoverPanics(name, builtin)}, nil
+	case object.BuiltinFunction:
+		return &object.Builtin{Fn: recoverPanics(name, builtin)}, nil
+	case func(args ...object.Object) object.Object:
+		return &object.Builtin{Fn: recoverPanics(name, func(ctx object.Context, args ...object.Object) object.Object {
+			return builtin(args...)
+		})}, nil
+	}
+
+	typ := fn.Type()
+	returnsError := typ.NumOut() > 0 && typ.Out(typ.NumOut()-1) == errorType
+	numValues := typ.NumOut()
+	if returnsError {
+		numValues--
+	}
+	if numValues > 1 {
+		return nil, fmt.Errorf("cannot convert %s to an object: too many results", typ)
+	}
+
+	// the context is passed before the arguments of the program
+	offset := 0
+	if typ.NumIn() > 0 && typ.In(0) == contextType {
+		offset = 1
+	}
+	required := typ.NumIn() - offset
+	if typ.IsVariadic() {
+		required--
+	}
+
+	return &object.Builtin{
+		Fn: recoverPanics(name, func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) < required || (!typ.IsVariadic() && len(args) > required) {
+				return evaluator.NewError("wrong number of arguments. got=%d, want=%d", len(args), required)
+			}
+
+			in := make([]reflect.Value, offset+len(args))
+			if offset == 1 {
+				in[0] = reflect.ValueOf(&ctx).Elem()
+			}
+			for i, arg := range args {
+				var argType reflect.Type
+				if i < required {
+					argType = typ.In(offset + i)
+				} else {
+					// the extra arguments of a variadic function
+					argType = typ.In(offset + required).Elem()
+				}
+
+				v, ok := objectToValue(arg, argType)
+				if !ok {
+					return evaluator.NewError("argument to `%s` not supported, got %s", name, arg.Type())
+				}
+				in[offset+i] = v
+			}
+
+			out := fn.Call(in)
+
+			if returnsError {
+				if err, _ := out[len(out)-1].Interface().(error); err != nil {
+					return &object.Error{Err: err}
+				}
+			}
+			if numValues == 0 {
+				return evaluator.NULL
+			}
+
+			result, err := valueToObject(out[0], "")
+			if err != nil {
+				return evaluator.NewError("%s", err)
+			}
+			return result
+		}),
+	}, nil
+}
+
+// recoverPanics turns a panic of the host function fn into a runtime error of the program calling it,
+// instead of crashing the Go program running the interpreter.
+func recoverPanics(name string, fn object.BuiltinFunction) object.BuiltinFunction {
+	return func(ctx object.Context, args ...object.Object) (result object.Object) {
+		defer func() {
+			if r := recover(); r != nil {
+				result = evaluator.NewError("panic in `%s`: %v", name, r)
+			}
+		}()
+		return fn(ctx, args...)
+	}
+}
@@ -0,0 +1,122 @@
+// Package enginetest holds the test scenarios that the evaluator and the virtual machine have to run alike.
+package enginetest
+
+import (
+	"testing"
+	"waixg/interpreter/ast"
+	"waixg/interpreter/lexer"
+	"waixg/interpreter/object"
+	"waixg/interpreter/parser"
+)
+
+// Case is a program together with its expected result: an int, float64 or bool for a value of that type,
+// []int for an array of integers, nil for null, or a Str, Inspect or Error.
+type Case struct {
+	Input    string
+	Expected interface{}
+}
+
+// Str is an expected string value
+type Str string
+
+// Inspect is the expected Inspect() of the result, e.g. of an array or of an error with its position
+type Inspect string
+
+// Error is the expected message of a runtime error
+type Error string
+
+// Group is a set of cases exercising the same feature
+type Group struct {
+	Name  string
+	Cases []Case
+}
+
+// Run runs every group of Scenarios as a subtest. run evaluates a program and returns its result,
+// or its error as an *object.Error.
+func Run(t *testing.T, run func(program *ast.Program) object.Object) {
+	for _, g := range Scenarios {
+		t.Run(g.Name, func(t *testing.T) {
+			for _, c := range g.Cases {
+				p := parser.New(lexer.New(c.Input))
+				program := p.ParseProgram()
+				if len(p.Errors()) != 0 {
+					t.Errorf("%q: parser errors: %v", c.Input, p.Errors())
+					continue
+				}
+
+				check(t, c, run(program))
+			}
+		})
+	}
+}
+
+func check(t *testing.T, c Case, result object.Object) {
+	t.Helper()
+
+	switch expected := c.Expected.(type) {
+	case int:
+		checkInteger(t, c.Input, result, int64(expected))
+	case float64:
+		float, ok := result.(*object.Float)
+		if !ok {
+			t.Errorf("%q: object is not Float. got=%T (%+v)", c.Input, result, result)
+		} else if float.Value != expected {
+			t.Errorf("%q: object has wrong value. got=%g, want=%g", c.Input, float.Value, expected)
+		}
+	case bool:
+		boolean, ok := result.(*object.Boolean)
+		if !ok {
+			t.Errorf("%q: object is not Boolean. got=%T (%+v)", c.Input, result, result)
+		} else if boolean.Value != expected {
+			t.Errorf("%q: object has wrong value. got=%t, want=%t", c.Input, boolean.Value, expected)
+		}
+	case []int:
+		array, ok := result.(*object.Array)
+		if !ok {
+			t.Errorf("%q: object is not Array. got=%T (%+v)", c.Input, result, result)
+			return
+		}
+		if len(array.Elements) != len(expected) {
+			t.Errorf("%q: array has wrong num of elements. got=%d, want=%d", c.Input, len(array.Elements), len(expected))
+			return
+		}
+		for i, el := range expected {
+			checkInteger(t, c.Input, array.Elements[i], int64(el))
+		}
+	case nil:
+		if result == nil || result.Type() != object.NullObj {
+			t.Errorf("%q: object is not NULL. got=%T (%+v)", c.Input, result, result)
+		}
+	case Str:
+		str, ok := result.(*object.String)
+		if !ok {
+			t.Errorf("%q: object is not String. got=%T (%+v)", c.Input, result, result)
+		} else if str.Value != string(expected) {
+			t.Errorf("%q: String has wrong value. got=%q, want=%q", c.Input, str.Value, expected)
+		}
+	case Inspect:
+		if result == nil || result.Inspect() != string(expected) {
+			t.Errorf("%q: wrong result. expected=%q, got=%+v", c.Input, expected, result)
+		}
+	case Error:
+		errObj, ok := result.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T (%+v)", c.Input, result, result)
+		} else if errObj.Err.Error() != string(expected) {
+			t.Errorf("%q: wrong error message. expected=%q, got=%q", c.Input, expected, errObj.Err.Error())
+		}
+	default:
+		t.Fatalf("%q: unsupported expected result %T", c.Input, expected)
+	}
+}
+
+func checkInteger(t *testing.T, input string, obj object.Object, expected int64) {
+	t.Helper()
+
+	integer, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("%q: object is not Integer. got=%T (%+v)", input, obj, obj)
+	} else if integer.Value != expected {
+		t.Errorf("%q: object has wrong value. got=%d, want=%d", input, integer.Value, expected)
+	}
+}
@@ -0,0 +1,783 @@
+package enginetest
+
+// Scenarios are the programs that both engines run, grouped by feature
+var Scenarios = []Group{
+	{"IntegerExpressions", []Case{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"5^2", 25},
+		{"5^2^2", 625},
+		{"5*3^2^2", 405},
+		{"3^39", 4052555153018976267},
+		{"2^62", 4611686018427387904},
+		{"-2^63", -9223372036854775808},
+		{"9223372036854775807 + 0", 9223372036854775807},
+		{"-9223372036854775807 - 1", -9223372036854775808},
+		{"-4611686018427387904 * 2", -9223372036854775808},
+		{"-7 / 2", -3},
+		{"2^0", 1},
+		{"-2^3", -8},
+		{"2^3^2", 512},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 % -3", 1},
+		{"2 + 7 % 3 * 2", 4},
+		{"(-9223372036854775807 - 1) % -1", 0},
+		{"12 & 10", 8},
+		{"12 | 10", 14},
+		{"12 ~ 10", 6},
+		{"~5", -6},
+		{"~-1", 0},
+		{"1 << 10", 1024},
+		{"1024 >> 3", 128},
+		{"-16 >> 2", -4},
+		{"1 << 64", 0},
+		{"1 << 2 + 1", 8},
+		{"6 & 3 | 8", 10},
+		{"1 | 6 ~ 3 & 5", 7},
+		{"let x = 13; x & 1", 1},
+		{"1 + 2", 3},
+		{"let xs = [7]; xs[0] = xs[0] % 4; xs[0]", 3},
+		{"1; 2", 2},
+	}},
+	{"FloatExpressions", []Case{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1e-9", 1e-9},
+		{"0.1 + 0.2", 0.30000000000000004},
+		{"1.5 * 2", 3.0},
+		{"2 * 1.5", 3.0},
+		{"7 / 2.0", 3.5},
+		{"1 - 0.5", 0.5},
+		{"2.0 ^ 0.5", 1.4142135623730951},
+		{"2 ^ -1", 0.5},
+		{"let x = 1; x += 0.5; x", 1.5},
+		{"7.5 % 2", 1.5},
+		{"-7.5 % 2", -1.5},
+		{"7 % 2.5", 2.0},
+		{"2 ^ 2 ^ -1", 1.4142135623730951},
+	}},
+	{"FloatInspect", []Case{
+		{"3.0", Inspect("3.0")},
+		{"3.25", Inspect("3.25")},
+		{"1e21", Inspect("1e+21")},
+		{"1e308 * 10", Inspect("+Inf")},
+	}},
+	{"BooleanExpressions", []Case{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"false != true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+		{"1<=2", true},
+		{"1>=2", false},
+		{"1<=1", true},
+		{"1>=1", true},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1 == 1.0", true},
+		{"1.0 != 1", false},
+		{"0.1 + 0.2 == 0.3", false},
+		{"2.5 >= 2.5", true},
+		{"1 <= 1", true},
+		{"2 >= 3", false},
+	}},
+	{"BangOperator", []Case{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}},
+	{"LogicalOperators", []Case{
+		{"true && true", Inspect("true")},
+		{"true && false", Inspect("false")},
+		{"false || true", Inspect("true")},
+		{"false || false", Inspect("false")},
+		{"true and false", Inspect("false")},
+		{"false or true", Inspect("true")},
+		{"1 && 2", Inspect("2")},
+		{"0 && 2", Inspect("2")},
+		{"false && 2", Inspect("false")},
+		{`if (false) { 1 } || "default"`, Inspect("default")},
+		{`"name" || "default"`, Inspect("name")},
+		{"[] || [1]", Inspect("[]")},
+		{"1 < 2 && 2 < 3", Inspect("true")},
+		{"1 > 2 || 2 > 3", Inspect("false")},
+		{"false && x", Inspect("false")},
+		{"true || x", Inspect("true")},
+		{"false && 1 / 0", Inspect("false")},
+		{"let n = 0; false && (n = 1); true || (n = 2); n", Inspect("0")},
+		{"let n = 0; true && (n = 1); false || (n += 2); n", Inspect("3")},
+		{"let f = fn(xs) { len(xs) > 0 && xs[0] }; [f([]), f([5])]", Inspect("[false, 5]")},
+		{"if (1 > 2 || 3 > 2) { 10 } else { 20 }", Inspect("10")},
+		{"true and 1 < 2", true},
+		{"false or false", false},
+		{"true || 1 / 0", true},
+		{"let f = fn(xs) { len(xs) > 0 && xs[0] }; f([]) || f([5])", 5},
+		{"let f = fn(x) { x > 1 or x < -1 }; filter([-2, 0, 2], f)", []int{-2, 2}},
+	}},
+	{"IfElseExpressions", []Case{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (true) { 10 } else { 20 }", 10},
+		{"if (false) { 10 } else { 20 }", 20},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (true) {}", nil},
+	}},
+	{"ReturnStatements", []Case{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{`
+if (10 > 1) {
+	if (10 > 1) {
+		return 10;
+	}
+
+	return 1;
+}
+`, 10},
+		{`
+let f = fn(x) {
+	return x;
+	x + 10;
+};
+
+f(10);
+`, 10},
+		{`
+let f = fn(x) {
+	let result = x + 10;
+	return result;
+	return 10;
+};
+
+f(10);
+`, 20},
+		{"return 7; 8", 7},
+	}},
+	{"ErrorHandling", []Case{
+		{"5 + true;", Error("type mismatch: INTEGER + BOOLEAN")},
+		{"5 + true; 5;", Error("type mismatch: INTEGER + BOOLEAN")},
+		{"-true", Error("unknown operator: -BOOLEAN")},
+		{"true + false;", Error("unknown operator: BOOLEAN + BOOLEAN")},
+		{"5; true + false; 5", Error("unknown operator: BOOLEAN + BOOLEAN")},
+		{"if (10 > 1) { true + false; }", Error("unknown operator: BOOLEAN + BOOLEAN")},
+		{`
+if (10 > 1) {
+	if (10 > 1) {
+		return true + false;
+	}
+
+	return 1;
+}
+`, Error("unknown operator: BOOLEAN + BOOLEAN")},
+		{"foobar", Error("identifier not found: foobar")},
+		{`"Hello" - "World"`, Error("unknown operator: STRING - STRING")},
+		{"1 / 0", Error("division by zero: 1 / 0")},
+		{"let x = 0; 10 / x", Error("division by zero: 10 / 0")},
+		{"1.5 / 0", Error("division by zero: 1.5 / 0")},
+		{"1 / 0.0", Error("division by zero: 1 / 0.0")},
+		{"9223372036854775807 + 1", Error("integer overflow: 9223372036854775807 + 1")},
+		{"-9223372036854775807 - 2", Error("integer overflow: -9223372036854775807 - 2")},
+		{"4611686018427387904 * 2", Error("integer overflow: 4611686018427387904 * 2")},
+		{"(-9223372036854775807 - 1) / -1", Error("integer overflow: -9223372036854775808 / -1")},
+		{"(-9223372036854775807 - 1) * -1", Error("integer overflow: -9223372036854775808 * -1")},
+		{"-(-9223372036854775807 - 1)", Error("integer overflow: -(-9223372036854775808)")},
+		{"2^63", Error("integer overflow: 2 ^ 63")},
+		{"10^19", Error("integer overflow: 10 ^ 19")},
+		{"1 % 0", Error("division by zero: 1 % 0")},
+		{"1.5 % 0", Error("division by zero: 1.5 % 0")},
+		{"1 << -1", Error("negative shift count: 1 << -1")},
+		{"1.5 & 1", Error("unknown operator: FLOAT & INTEGER")},
+		{"true | false", Error("unknown operator: BOOLEAN | BOOLEAN")},
+		{"~1.5", Error("unknown operator: ~FLOAT")},
+		{`~"a"`, Error("unknown operator: ~STRING")},
+		{`{"name": "Monkey"}[fn(x) { x }];`, Error("unusable as hash key: FUNCTION")},
+		{`{fn(x) { x }: 1};`, Error("unusable as hash key: FUNCTION")},
+		{"let f = fn() { x }; f()", Error("identifier not found: x")},
+		{"let f = fn() { let a = b; let b = 1; }; f()", Error("identifier not found: b")},
+		{"1 >> -1", Error("negative shift count: 1 >> -1")},
+		{"~true", Error("unknown operator: ~BOOLEAN")},
+		{"1[0]", Error("index operator not supported: INTEGER")},
+		{"1()", Error("not a function: INTEGER")},
+		{"len(1)", Error("argument to `len` not supported, got INTEGER")},
+	}},
+	{"LetStatements", []Case{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = one + one; one + two", 3},
+		{"let a = 1; let a = a + 1; a", 2},
+	}},
+	{"FunctionApplication", []Case{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let f = fn() { 5 + 10; }; f();", 15},
+		{"let one = fn() { 1; }; let two = fn() { 2; }; one() + two()", 3},
+		{"let f = fn() { return 99; 100; }; f();", 99},
+		{"let f = fn() { }; f();", nil},
+		{"let f = fn() { }; let g = fn() { let a = 1; }; [f(), g(), if (true) {}]", Inspect("[null, null, null]")},
+		{"let f = fn(a, b) { a + b }; f(1, 2);", 3},
+		{"let f = fn() { let a = 1; let b = 2; a + b }; f();", 3},
+		{"let g = 50; let f = fn() { let a = 1; g - a }; f() + f();", 98},
+		{"fn(x) { x * 2 }(21)", 42},
+		{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)", 610},
+		{"let f = fn() { let g = fn(n) { if (n == 0) { 0 } else { g(n - 1) } }; g(5) }; f()", 0},
+		{"let f = fn() { g() }; let g = fn() { 3 }; f()", 3},
+	}},
+	{"FunctionDefaultAndRestParameters", []Case{
+		{"let add = fn(a, b = 2) { a + b }; add(1);", 3},
+		{"let add = fn(a, b = 2) { a + b }; add(1, 5);", 6},
+		{"let f = fn(a = 1, b = a * 10) { a + b }; f();", 11},
+		{"let f = fn(a = 1, b = a * 10) { a + b }; f(2);", 22},
+		{"let count = fn(...rest) { len(rest) }; count();", 0},
+		{"let count = fn(...rest) { len(rest) }; count(1, 2, 3);", 3},
+		{"let f = fn(a, ...rest) { a + len(rest) }; f(10);", 10},
+		{"let f = fn(a, ...rest) { a + rest[1] }; f(10, 1, 2);", 12},
+		{"let f = fn(a, b = 5, ...rest) { a + b + len(rest) }; f(1);", 6},
+		{"let f = fn(a, b = 5, ...rest) { a + b + len(rest) }; f(1, 2, 3, 4);", 5},
+	}},
+	{"FunctionArity", []Case{
+		{"let add = fn(a, b) { a + b }; add(1);", Error("wrong number of arguments to `add`. got=1, want=2")},
+		{"let add = fn(a, b) { a + b }; add(1, 2, 3);", Error("wrong number of arguments to `add`. got=3, want=2")},
+		{"fn() { 1 }(1);", Error("wrong number of arguments to anonymous function. got=1, want=0")},
+		{"let f = fn(a, b = 1) { a }; f();", Error("wrong number of arguments to `f`. got=0, want=1 to 2")},
+		{"let f = fn(a, b = 1) { a }; f(1, 2, 3);", Error("wrong number of arguments to `f`. got=3, want=1 to 2")},
+		{"let f = fn(a, ...rest) { a }; f();", Error("wrong number of arguments to `f`. got=0, want=at least 1")},
+		{"let f = fn(a = x) { a }; f();", Error("identifier not found: x")},
+	}},
+	{"FunctionInspect", []Case{
+		{"fn(x) { x + 2; };", Inspect("fn(x) {\n(x + 2)\n}")},
+		{"let f = fn(a, b = 2, ...rest) { a }; f", Inspect("fn(a, b = 2, ...rest) {\na\n}")},
+		{"[fn() {}]", Inspect("[fn() {\n\n}]")},
+	}},
+	{"Closures", []Case{
+		{`
+let newAdder = fn(x) {
+	fn(y) { x + y };
+};
+
+let addTwo = newAdder(2);
+addTwo(2);
+`, 4},
+		{"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(2);", 4},
+		{"let f = fn(a) { fn(b) { fn(c) { a + b + c } } }; f(1)(2)(3)", 6},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let a = counter(); let b = counter(); a(); a(); b()", 1},
+		{"let f = fn() { let x = 1; let g = fn() { fn() { x = 10 } }; g()(); x }; f()", 10},
+	}},
+	{"Strings", []Case{
+		{`"Hello World!";`, Str("Hello World!")},
+		{`"Hello" + " " + "World!";`, Str("Hello World!")},
+		{`"monkey"`, Str("monkey")},
+		{`"mon" + "key"`, Str("monkey")},
+	}},
+	{"InterpolatedStrings", []Case{
+		{`let a = 1; let b = 2; "sum is ${a + b}"`, Str("sum is 3")},
+		{`"n=" + "${1}"`, Str("n=1")},
+		{`"${1.5} ${true} ${[1, "a"]} ${if (false) { 1 }}"`, Str("1.5 true [1, a] null")},
+		{`let name = "world"; "hello, ${name}!"`, Str("hello, world!")},
+		{`"outer ${"inner ${1 + 1}"}"`, Str("outer inner 2")},
+		{`let f = fn() { let x = 1; }; "${f()}"`, Str("null")},
+		{`"\${not interpolated}"`, Str("${not interpolated}")},
+		{`"value: ${1 + true}"`, Inspect("ERROR: 1:13: type mismatch: INTEGER + BOOLEAN")},
+		{`let a = 1; "sum is ${a + 2}"`, Str("sum is 3")},
+		{`"${[1, "a"]} ${"in ${true}"}"`, Str("[1, a] in true")},
+		{`let f = fn(n) { "n=${n}" }; f(1.5)`, Str("n=1.5")},
+	}},
+	{"BuiltinFunctions", []Case{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("\t\"\n")`, 3},
+		{"len(`\\n`)", 2},
+		{`len("hello world")`, 11},
+		{`len("größe")`, 5},
+		{`len("😀")`, 1},
+		{`bytes("")`, []int{}},
+		{`bytes("aé")`, []int{97, 195, 169}},
+		{`len(bytes("größe"))`, 7},
+		{`bytes(1)`, Error("argument to `bytes` not supported, got INTEGER")},
+		{`bytes("a", "b")`, Error("wrong number of arguments. got=2, want=1")},
+		{`len(1)`, Error("argument to `len` not supported, got INTEGER")},
+		{`len("one", "two")`, Error("wrong number of arguments. got=2, want=1")},
+		{`int(3.9)`, 3},
+		{`int(-3.9)`, -3},
+		{`int(7)`, 7},
+		{`int(" 42 ")`, 42},
+		{`int("4.2")`, Error(`cannot convert "4.2" to INTEGER`)},
+		{`int(true)`, Error("argument to `int` not supported, got BOOLEAN")},
+		{`int(1e300)`, Error("cannot convert 1e+300 to INTEGER")},
+		{`int(1, 2)`, Error("wrong number of arguments. got=2, want=1")},
+		{`float(2)`, 2.0},
+		{`float("2.5")`, 2.5},
+		{`float(1.25)`, 1.25},
+		{`float("x")`, Error(`cannot convert "x" to FLOAT`)},
+		{`float([])`, Error("argument to `float` not supported, got ARRAY")},
+		{"idiv(7, 2)", 3},
+		{"idiv(-7, 2)", -3},
+		{"idiv(7.5, 2)", 3},
+		{"idiv(-7, 2.5)", -2},
+		{"idiv(1, 0)", Error("division by zero: 1 / 0")},
+		{"idiv(1.5, 0)", Error("division by zero: 1.5 / 0")},
+		{"idiv(-9223372036854775807 - 1, -1)", Error("integer overflow: -9223372036854775808 / -1")},
+		{"idiv(1e300, 1e-10)", Error("cannot convert +Inf to INTEGER")},
+		{`idiv("7", 2)`, Error("argument to `idiv` not supported, got STRING")},
+		{"idiv(7)", Error("wrong number of arguments. got=1, want=2")},
+		{`len([])`, 0},
+		{`len([1, 2, 3])`, 3},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, Error("argument to `first` not supported, got INTEGER")},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`last(1)`, Error("argument to `last` not supported, got INTEGER")},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([])`, nil},
+		{`rest(1)`, Error("argument to `rest` not supported, got INTEGER")},
+		{`push([], 1)`, []int{1}},
+		{`push(1, 1)`, Error("argument to `push` not supported, got INTEGER")},
+		{"len([1, 2, 3])", 3},
+	}},
+	{"StringBuiltins", []Case{
+		{`split("a,b,c", ",")`, Inspect("[a, b, c]")},
+		{`split("größe", "")`, Inspect("[g, r, ö, ß, e]")},
+		{`split("", ",")`, Inspect("[]")},
+		{`join(["a", "b"], ", ")`, Inspect("a, b")},
+		{`join([1, true, "x"], "-")`, Inspect("1-true-x")},
+		{`join([], ",")`, Inspect("")},
+		{`trim("  a b \n")`, Inspect("a b")},
+		{`upper("größe")`, Inspect("GRÖßE")},
+		{`lower("ABC")`, Inspect("abc")},
+		{`replace("a-b-c", "-", "+")`, Inspect("a+b+c")},
+		{`contains("hello", "ell")`, Inspect("true")},
+		{`contains("hello", "x")`, Inspect("false")},
+		{`startsWith("hello", "he")`, Inspect("true")},
+		{`endsWith("hello", "he")`, Inspect("false")},
+		{`indexOf("größe", "e")`, Inspect("4")},
+		{`indexOf("hello", "x")`, Inspect("-1")},
+		{`substr("größe", 1)`, Inspect("röße")},
+		{`substr("größe", 1, 2)`, Inspect("rö")},
+		{`substr("größe", -2)`, Inspect("ße")},
+		{`substr("abc", 1, 10)`, Inspect("bc")},
+		{`substr("abc", 5)`, Inspect("")},
+		{`substr("abc", -5, 1)`, Inspect("a")},
+		{`repeat("ab", 3)`, Inspect("ababab")},
+		{`repeat("ab", 0)`, Inspect("")},
+		{`format("%d + %.1f = %s", 1, 2.5, "x")`, Inspect("1 + 2.5 = x")},
+		{`format("%v %t %v", [1], true, if (false) { 1 })`, Inspect("[1] true null")},
+		{`sprintf("%05d", 42)`, Inspect("00042")},
+		{`format("plain")`, Inspect("plain")},
+		{`format("%5.2f|%-4d|%x|%X|%q|%c|%e|%%", 3, 7, "hi", 255, "a", 65, 1.5)`, Inspect(` 3.00|7   |6869|FF|"a"|A|1.500000e+00|%`)},
+		{`format("%s and %v", [1, "a"], {"k": true})`, Inspect("[1, a] and {k: true}")},
+		{`format("%+05d %#x %t", 3, 10, false)`, Inspect("+0003 0xa false")},
+		{`format("é%sü", "ö")`, Inspect("éöü")},
+		{`split("a")`, Error("wrong number of arguments. got=1, want=2")},
+		{`split(1, ",")`, Error("argument to `split` not supported, got INTEGER")},
+		{`join("a", ",")`, Error("argument to `join` not supported, got STRING")},
+		{`trim(1)`, Error("argument to `trim` not supported, got INTEGER")},
+		{`upper()`, Error("wrong number of arguments. got=0, want=1")},
+		{`lower("a", "b")`, Error("wrong number of arguments. got=2, want=1")},
+		{`replace("a", "b")`, Error("wrong number of arguments. got=2, want=3")},
+		{`replace("a", "b", 1)`, Error("argument to `replace` not supported, got INTEGER")},
+		{`contains(1, "a")`, Error("argument to `contains` not supported, got INTEGER")},
+		{`startsWith("a", 1)`, Error("argument to `startsWith` not supported, got INTEGER")},
+		{`endsWith(1, "a")`, Error("argument to `endsWith` not supported, got INTEGER")},
+		{`indexOf("a", true)`, Error("argument to `indexOf` not supported, got BOOLEAN")},
+		{`substr("a")`, Error("wrong number of arguments. got=1, want=2 or 3")},
+		{`substr("a", "b")`, Error("argument to `substr` not supported, got STRING")},
+		{`substr("abc", 0, -1)`, Error("negative length in `substr`: -1")},
+		{`repeat("a", -1)`, Error("negative count in `repeat`: -1")},
+		{`repeat("ab", 9223372036854775807)`, Error("count in `repeat` too large: 9223372036854775807")},
+		{`repeat("a", 1000000000000000000)`, Error("count in `repeat` too large: 1000000000000000000")},
+		{`format("%d-%s", 1)`, Error("wrong number of arguments to `format`. got=2, want=3")},
+		{`format("%d", 1, 2)`, Error("wrong number of arguments to `format`. got=3, want=2")},
+		{`format("%d", "a")`, Error("argument for %d in `format` not supported, got STRING")},
+		{`format("%t", 1)`, Error("argument for %t in `format` not supported, got INTEGER")},
+		{`format("%y", 1)`, Error("unknown verb in `format`: %y")},
+		{`format("%[1]d", 1)`, Error("unknown verb in `format`: %[")},
+		{`format("100%")`, Error("incomplete verb in `format`: %")},
+		{`format("%999999999d", 1)`, Error("width or precision too large in `format`: %999999999d")},
+		{`sprintf("%.1000001f", 1.5)`, Error("width or precision too large in `sprintf`: %.1000001f")},
+		{`format()`, Error("wrong number of arguments. got=0, want at least 1")},
+		{`format(1)`, Error("argument to `format` not supported, got INTEGER")},
+		{`sprintf(1)`, Error("argument to `sprintf` not supported, got INTEGER")},
+		{`bytes("é")`, []int{195, 169}},
+		{`join(split(upper("a,b"), ","), "+")`, Str("A+B")},
+		{`substr("a", 1, -1)`, Error("negative length in `substr`: -1")},
+	}},
+	{"ArrayBuiltins", []Case{
+		{`push([1], 2, 3)`, Inspect("[1, 2, 3]")},
+		{`let a = [1]; push(a, 2); a`, Inspect("[1]")},
+		{`pop([1, 2, 3])`, Inspect("[1, 2]")},
+		{`pop([])`, Inspect("null")},
+		{`let a = [1, 2]; pop(a); a`, Inspect("[1, 2]")},
+		{`slice([1, 2, 3, 4], 1)`, Inspect("[2, 3, 4]")},
+		{`slice([1, 2, 3, 4], 1, 3)`, Inspect("[2, 3]")},
+		{`slice([1, 2, 3, 4], -2)`, Inspect("[3, 4]")},
+		{`slice([1, 2, 3, 4], 0, -1)`, Inspect("[1, 2, 3]")},
+		{`slice([1, 2, 3], 2, 1)`, Inspect("[]")},
+		{`slice([1, 2, 3], -10, 10)`, Inspect("[1, 2, 3]")},
+		{`let a = [1, 2, 3]; let b = slice(a, 0, 2); b[0] = 9; a`, Inspect("[1, 2, 3]")},
+		{`concat([1], [], [2, 3])`, Inspect("[1, 2, 3]")},
+		{`concat()`, Inspect("[]")},
+		{`reverse([1, 2, 3])`, Inspect("[3, 2, 1]")},
+		{`let a = [1, 2]; reverse(a); a`, Inspect("[1, 2]")},
+		{`contains([1, "a", [2]], "a")`, Inspect("true")},
+		{`contains([1, "a", [2]], [2])`, Inspect("true")},
+		{`contains([1, 2], 2.0)`, Inspect("true")},
+		{`contains([1, 2], "2")`, Inspect("false")},
+		{`contains("abc", "b")`, Inspect("true")},
+		{`indexOf([1, true, "x"], "x")`, Inspect("2")},
+		{`indexOf([1, true], false)`, Inspect("-1")},
+		{`let f = fn() {}; indexOf([1, f], f)`, Inspect("1")},
+		{`range(3)`, Inspect("[0, 1, 2]")},
+		{`range(2, 5)`, Inspect("[2, 3, 4]")},
+		{`range(0, 10, 3)`, Inspect("[0, 3, 6, 9]")},
+		{`range(5, 0, -2)`, Inspect("[5, 3, 1]")},
+		{`range(5, 2)`, Inspect("[]")},
+		{`range(9223372036854775806, 9223372036854775807, 2)`, Inspect("[9223372036854775806]")},
+		{`zip([1, 2, 3], ["a", "b"])`, Inspect("[[1, a], [2, b]]")},
+		{`zip([1, 2])`, Inspect("[[1], [2]]")},
+		{`zip([1], [])`, Inspect("[]")},
+		{`first()`, Error("wrong number of arguments. got=0, want=1")},
+		{`last([1], [2])`, Error("wrong number of arguments. got=2, want=1")},
+		{`push([])`, Error("wrong number of arguments. got=1, want at least 2")},
+		{`pop("abc")`, Error("argument to `pop` not supported, got STRING")},
+		{`slice([1])`, Error("wrong number of arguments. got=1, want=2 or 3")},
+		{`slice([1], "a")`, Error("argument to `slice` not supported, got STRING")},
+		{`slice("abc", 1)`, Error("argument to `slice` not supported, got STRING")},
+		{`concat([1], 2)`, Error("argument to `concat` not supported, got INTEGER")},
+		{`reverse({})`, Error("argument to `reverse` not supported, got HASH")},
+		{`contains([1])`, Error("wrong number of arguments. got=1, want=2")},
+		{`contains(1, 1)`, Error("argument to `contains` not supported, got INTEGER")},
+		{`indexOf({}, 1)`, Error("argument to `indexOf` not supported, got HASH")},
+		{`range()`, Error("wrong number of arguments. got=0, want=1 to 3")},
+		{`range(1, 2, 3, 4)`, Error("wrong number of arguments. got=4, want=1 to 3")},
+		{`range(1.5)`, Error("argument to `range` not supported, got FLOAT")},
+		{`range(0, 5, 0)`, Error("zero step in `range`")},
+		{`zip()`, Error("wrong number of arguments. got=0, want at least 1")},
+		{`zip([1], "a")`, Error("argument to `zip` not supported, got STRING")},
+		{"first([1, 2])", 1},
+		{"last([])", nil},
+		{"rest([1, 2, 3])", []int{2, 3}},
+		{"let a = [1]; push(a, 2); a", []int{1}},
+		{"slice(range(10), 2, 5)", []int{2, 3, 4}},
+		{"reverse(concat([1], [2, 3]))", []int{3, 2, 1}},
+		{"indexOf(range(5, 0, -1), 3)", 2},
+		{"len(zip([1, 2], [3, 4]))", 2},
+		{"first(1)", Error("argument to `first` not supported, got INTEGER")},
+	}},
+	{"HigherOrderBuiltins", []Case{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, Inspect("[2, 4, 6]")},
+		{`map([], fn(x) { x })`, Inspect("[]")},
+		{`map(["a", "bc"], len)`, Inspect("[1, 2]")},
+		{`map([1], fn(x) { let y = x; })`, Inspect("[null]")},
+		{`let n = 10; map([1, 2], fn(x) { x + n })`, Inspect("[11, 12]")},
+		{`filter(range(6), fn(x) { x > 3 })`, Inspect("[4, 5]")},
+		{`filter([1, 2], fn(x) { false })`, Inspect("[]")},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x })`, Inspect("6")},
+		{`reduce([1, 2, 3], fn(acc, x) { push(acc, x * x) }, [])`, Inspect("[1, 4, 9]")},
+		{`reduce([], fn(acc, x) { acc + x }, 0)`, Inspect("0")},
+		{`reduce([5], fn(acc, x) { acc + x })`, Inspect("5")},
+		{`sort([3, 1.5, 2])`, Inspect("[1.5, 2, 3]")},
+		{`sort(["b", "c", "a"])`, Inspect("[a, b, c]")},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, Inspect("[3, 2, 1]")},
+		{`sort([3, 1, 2], fn(a, b) { a - b })`, Inspect("[1, 2, 3]")},
+		{`sort([[2, "a"], [1, "b"], [2, "c"], [1, "d"]], fn(a, b) { a[0] < b[0] })`, Inspect("[[1, b], [1, d], [2, a], [2, c]]")},
+		{`let a = [2, 1]; sort(a); a`, Inspect("[2, 1]")},
+		{`sort([])`, Inspect("[]")},
+		{`any([1, 2, 3], fn(x) { x > 2 })`, Inspect("true")},
+		{`any([1, 2, 3], fn(x) { x > 3 })`, Inspect("false")},
+		{`any([false, 0])`, Inspect("true")},
+		{`any([])`, Inspect("false")},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, Inspect("true")},
+		{`all([1, 2, 3], fn(x) { x > 1 })`, Inspect("false")},
+		{`all([true, false])`, Inspect("false")},
+		{`all([])`, Inspect("true")},
+		{`let calls = [0]; any([1, 2, 3], fn(x) { calls[0] += 1; x == 2 }); calls[0]`, Inspect("2")},
+		{`groupBy([1, 2, 3, 4], fn(x) { x / 2 * 2 == x })[true]`, Inspect("[2, 4]")},
+		{`groupBy(["aa", "b", "cc"], len)[2]`, Inspect("[aa, cc]")},
+		{`groupBy([], len)`, Inspect("{}")},
+		{`map([[1, 2], [3]], fn(xs) { reduce(xs, fn(a, b) { a + b }) })`, Inspect("[3, 3]")},
+		{`let f = fn(n) { if (n == 0) { 0 } else { reduce([n], fn(acc, x) { acc + x + f(n - 1) }, 0) } }; f(100)`, Inspect("5050")},
+		{`map([1])`, Inspect("ERROR: 1:4: wrong number of arguments. got=1, want=2")},
+		{`map(1, len)`, Inspect("ERROR: 1:4: argument to `map` not supported, got INTEGER")},
+		{`filter([1], 1)`, Inspect("ERROR: 1:7: argument to `filter` not supported, got INTEGER")},
+		{"map([1, 2], fn(x) {\n  x + true\n})", Inspect("ERROR: 2:5: type mismatch: INTEGER + BOOLEAN")},
+		{`map([1], fn(x, y) { x })`, Inspect("ERROR: 1:4: wrong number of arguments to anonymous function. got=1, want=2")},
+		{`filter([1], fn(x) { x[0] })`, Inspect("ERROR: 1:22: index operator not supported: INTEGER")},
+		{`reduce([1])`, Inspect("ERROR: 1:7: wrong number of arguments. got=1, want=2 or 3")},
+		{`reduce([], fn(a, b) { a })`, Inspect("ERROR: 1:7: `reduce` of an empty array without an initial value")},
+		{`reduce([1, 2], fn(a, b) { a + "x" })`, Inspect("ERROR: 1:29: type mismatch: INTEGER + STRING")},
+		{`sort([1, "a"])`, Inspect("ERROR: 1:5: cannot compare STRING and INTEGER in `sort`")},
+		{`sort([1, 2], fn(a, b) { "x" })`, Inspect("ERROR: 1:5: comparator of `sort` must return BOOLEAN or INTEGER, got STRING")},
+		{`sort([1, 2], fn(a, b) { a + true })`, Inspect("ERROR: 1:27: type mismatch: INTEGER + BOOLEAN")},
+		{`sort([1], 2)`, Inspect("ERROR: 1:5: argument to `sort` not supported, got INTEGER")},
+		{`any()`, Inspect("ERROR: 1:4: wrong number of arguments. got=0, want=1 or 2")},
+		{`all([1], fn(x) { -true })`, Inspect("ERROR: 1:18: unknown operator: -BOOLEAN")},
+		{`groupBy([1], fn(x) { [x] })`, Inspect("ERROR: 1:8: unusable as hash key: ARRAY")},
+		{"map([1, 2, 3], fn(x) { x * 2 })", []int{2, 4, 6}},
+		{"let n = 10; let f = fn() { let m = 1; map([1, 2], fn(x) { x + n + m }) }; f()", []int{12, 13}},
+		{"filter(range(6), fn(x) { x > 3 })", []int{4, 5}},
+		{"reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)", 16},
+		{"sort([3, 1, 2], fn(a, b) { a > b })", []int{3, 2, 1}},
+		{"any([1, 2], fn(x) { x > 1 })", true},
+		{"all([1, 2], fn(x) { x > 1 })", false},
+		{`len(groupBy(["aa", "b", "cc"], len)[2])`, 2},
+		{"map([[1, 2], [3]], fn(xs) { reduce(xs, fn(a, b) { a + b }) })", []int{3, 3}},
+		{"let f = fn(n) { if (n == 0) { 0 } else { reduce([n], fn(acc, x) { acc + x + f(n - 1) }, 0) } }; f(100)", 5050},
+		{"let xs = map([1, 2], fn(x) { return x + 1; }); let y = 5; y + xs[1]", 8},
+		{"map([1, 2], fn(x) { x + true })", Error("type mismatch: INTEGER + BOOLEAN")},
+		{"map([1], fn(x, y) { x })", Error("wrong number of arguments to anonymous function. got=1, want=2")},
+	}},
+	{"ArrayLiterals", []Case{
+		{"[1, 2 * 2, 3 + 3]", []int{1, 4, 6}},
+		{"[1 + 2, 3 * 4, 5 + 6]", []int{3, 12, 11}},
+	}},
+	{"ArrayIndexExpressions", []Case{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-4]", nil},
+		{"[][0]", nil},
+	}},
+	{"StringIndexExpressions", []Case{
+		{`"abc"[0]`, Str("a")},
+		{`"größe"[2]`, Str("ö")},
+		{`"größe"[3]`, Str("ß")},
+		{`"a😀b"[1]`, Str("😀")},
+		{`"a😀b"[2]`, Str("b")},
+		{`"größe"[-1]`, Str("e")},
+		{`"größe"[5]`, nil},
+		{`"größe"[-6]`, nil},
+		{`""[0]`, nil},
+	}},
+	{"SliceExpressions", []Case{
+		{"[1, 2, 3, 4][1:3]", Inspect("[2, 3]")},
+		{"[1, 2, 3, 4][1:]", Inspect("[2, 3, 4]")},
+		{"[1, 2, 3, 4][:2]", Inspect("[1, 2]")},
+		{"[1, 2, 3, 4][:]", Inspect("[1, 2, 3, 4]")},
+		{"[1, 2, 3, 4][-2:]", Inspect("[3, 4]")},
+		{"[1, 2, 3, 4][:-1]", Inspect("[1, 2, 3]")},
+		{"[1, 2, 3, 4][-10:10]", Inspect("[1, 2, 3, 4]")},
+		{"[1, 2, 3, 4][3:1]", Inspect("[]")},
+		{"[1, 2, 3, 4][5:]", Inspect("[]")},
+		{"[1, 2, 3, 4][::2]", Inspect("[1, 3]")},
+		{"[1, 2, 3, 4][1::2]", Inspect("[2, 4]")},
+		{"[1, 2, 3, 4][::-1]", Inspect("[4, 3, 2, 1]")},
+		{"[1, 2, 3, 4][2::-1]", Inspect("[3, 2, 1]")},
+		{"[1, 2, 3, 4][:0:-1]", Inspect("[4, 3, 2]")},
+		{"[1, 2, 3, 4][10:-10:-2]", Inspect("[4, 2]")},
+		{"[][::-1]", Inspect("[]")},
+		{"let xs = [1, 2, 3]; let ys = xs[:]; ys[0] = 5; xs", Inspect("[1, 2, 3]")},
+		{`"hello"[1:3]`, Inspect("el")},
+		{`"hello"[-3:]`, Inspect("llo")},
+		{`"hello"[::-1]`, Inspect("olleh")},
+		{`"größe"[1:4]`, Inspect("röß")},
+		{`"a😀b"[::-1]`, Inspect("b😀a")},
+		{`"größe"[::2]`, Inspect("göe")},
+		{`"hello"[4:1]`, Inspect("")},
+		{"[1, 2, 3, 4][::-2]", []int{4, 2}},
+		{"let xs = [1, 2, 3]; let i = 1; xs[i:][0]", 2},
+	}},
+	{"SliceErrors", []Case{
+		{"5[1:2]", Error("slice operator not supported: INTEGER")},
+		{`{"a": 1}[0:1]`, Error("slice operator not supported: HASH")},
+		{`[1, 2][1.5:]`, Error("slice index must be INTEGER, got FLOAT")},
+		{`"abc"[:"b"]`, Error("slice index must be INTEGER, got STRING")},
+		{`[1, 2][::0]`, Error("slice step cannot be zero")},
+		{`[1, 2][x:]`, Error("identifier not found: x")},
+		{"1[0:1]", Error("slice operator not supported: INTEGER")},
+		{"[1][::0]", Error("slice step cannot be zero")},
+	}},
+	{"HashIndexExpressions", []Case{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{1: 1, 2: 2}[1 + 1]`, 2},
+		{"{}[0]", nil},
+	}},
+	{"HashOrder", []Case{
+		{`{"b": 1, "a": 2, 3: 4, true: 5}`, Inspect("{b: 1, a: 2, 3: 4, true: 5}")},
+		{`{"a": 1, "b": 2, "a": 3}`, Inspect("{a: 3, b: 2}")},
+		{`let h = {"b": 1}; h["a"] = 2; h["b"] = 3; h`, Inspect("{b: 3, a: 2}")},
+		{`let ks = []; for (k in {"c": 1, "a": 2, "b": 3}) { ks = push(ks, k); } join(ks, "")`, Str("cab")},
+		{`let log = []; let f = fn(x) { log = push(log, x); x }; {f("b"): f(1), f("a"): f(2)}; log`, Inspect("[b, 1, a, 2]")},
+		{"groupBy([3, 1, 2, 1], fn(x) { x })", Inspect("{3: [3], 1: [1, 1], 2: [2]}")},
+	}},
+	{"ErrorPositions", []Case{
+		{"5 + true;", Inspect("ERROR: 1:3: type mismatch: INTEGER + BOOLEAN")},
+		{"let x = 1;\nlet y = -true;", Inspect("ERROR: 2:9: unknown operator: -BOOLEAN")},
+		{"let f = fn() {\n  foobar;\n};\nf();", Inspect("ERROR: 2:3: identifier not found: foobar")},
+		{`len(1)`, Inspect("ERROR: 1:4: argument to `len` not supported, got INTEGER")},
+	}},
+	{"WhileStatements", []Case{
+		{"let f = fn(n) { let acc = [0]; while (len(acc) < n) { let acc = [0, 0]; } len(acc) }; f(2)", 2},
+		{"let f = fn() { while (true) { return 5; } }; f()", 5},
+		{"let f = fn() { while (true) { break; } 7 }; f()", 7},
+		{"let f = fn(xs) { let n = 0; while (len(xs) > n) { let n = len(xs); continue; return 1; } n }; f([1, 2, 3])", 3},
+		{"let i = 0; while (i < 10) { i += 1; } i", 10},
+		{"let n = 0; let i = 0; while (i < 5) { i += 1; if (i == 2) { continue; } n += i; } n", 13},
+		{"while (false) { 1 }", nil},
+	}},
+	{"ForStatements", []Case{
+		{"let f = fn(xs) { for (x in xs) { if (x > 1) { return x; } } 0 }; f([1, 2, 3])", 2},
+		{"let f = fn(xs) { for (x in xs) { return x; } 0 }; f([])", 0},
+		{"let f = fn(xs) { for (x in xs) { if (x < 3) { continue; } return x; } 0 }; f([1, 2, 3])", 3},
+		{"let f = fn(xs) { for (x in xs) { if (x == 2) { break; } return x * 10; } 0 }; f([2, 3])", 0},
+		{`let f = fn(s) { for (c in s) { return len(c + c); } 0 }; f("abc")`, 2},
+		{`let f = fn(h) { for (k in h) { return h[k]; } }; f({"a": 4})`, 4},
+		{"let n = 0; for (x in [1, 2]) { for (y in [10, 20]) { if (y > 10) { break; } n += x * y; } } n", 30},
+		{"for (x in []) { 1 }", nil},
+	}},
+	{"LoopScopes", []Case{
+		{"let fs = []; for (i in [1, 2, 3]) { fs = push(fs, fn() { i }); } fs[0]()", 1},
+		{"let fs = []; for (i in [1, 2, 3]) { if (i == 2) { continue; } fs = push(fs, fn() { i }); } fs[1]()", 3},
+		{"let fs = []; for (i in [1, 2]) { let j = i * 10; fs = push(fs, fn() { j }); } fs[0]() + fs[1]()", 30},
+		{"let x = 10; for (x in [1, 2]) {}; x", 10},
+		{"let f = fn() { let x = 10; for (x in [1, 2]) { x = 5; } x }; f()", 10},
+		{"let f = fn() { let n = 0; for (x in [1, 2, 3]) { let g = fn() { n += x }; g(); } n }; f()", 6},
+		{"let n = 0; for (x in [1, 2]) { for (y in [x]) { n += y; continue; } n += x; } n", 6},
+		{"for (x in [1]) {}; x", Error("identifier not found: x")},
+		{"for (x in [1]) { let y = 1; }; y", Error("identifier not found: y")},
+	}},
+	{"LoopErrors", []Case{
+		{"break;", Error("break outside of loop")},
+		{"fn() { continue; }()", Error("continue outside of loop")},
+		{"let x = 1;\nbreak;", Inspect("ERROR: 2:1: break outside of loop")},
+		{"if (true) { break; }", Inspect("ERROR: 1:13: break outside of loop")},
+		{"let f = fn() {\n  continue;\n};\nf()", Inspect("ERROR: 2:3: continue outside of loop")},
+		{"for (x in 5) { x }", Error("cannot iterate over INTEGER")},
+		{"while (true) { 1 + true; }", Error("type mismatch: INTEGER + BOOLEAN")},
+		{"for (x in [1]) { y }", Error("identifier not found: y")},
+	}},
+	{"AssignExpressions", []Case{
+		{"let a = 5; a = 10; a;", 10},
+		{"let a = 5; a = 10;", 10},
+		{"let a = 1; let b = 1; a = b = 3; a + b;", 6},
+		{"let a = 5; a += 2; a;", 7},
+		{"let a = 5; a -= 2; a;", 3},
+		{"let a = 5; a *= 2; a;", 10},
+		{"let a = 6; a /= 2; a;", 3},
+		{"let a = 0; let inc = fn() { a += 1 }; inc(); inc(); a;", 2},
+		{"let counter = fn() { let n = 0; fn() { n = n + 1; n } }; let c = counter(); c(); c(); c();", 3},
+		{"let a = 1; let shadow = fn() { let a = 5; a = 6; }; shadow(); a;", 1},
+		{"let a = 0; for (x in [1, 2, 3]) { a += x; } a;", 6},
+		{"let i = 0; while (i < 10) { i += 1; if (i == 5) { break; } } i;", 5},
+		{"let arr = [1, 2, 3]; arr[0] = 5; arr[0];", 5},
+		{"let arr = [1, 2, 3]; arr[-1] += 5; arr[2];", 8},
+		{`let h = {"k": 1}; h["k"] = 5; h["k"];`, 5},
+		{`let h = {}; h["new"] = 3; h["new"];`, 3},
+		{`let h = {"k": 2}; h["k"] *= 4; h["k"];`, 8},
+		{"let f = fn() { a = 3 }; let a = 1; f(); a", 3},
+	}},
+	{"TailCalls", []Case{
+		{"let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } }; count(1000000, 0)", 1000000},
+		{"let count = fn(n, acc) { if (n == 0) { return acc; } return count(n - 1, acc + 1); }; count(100000, 0)", 100000},
+		{"let count = fn(n) { while (true) { if (n == 0) { return 0; } return count(n - 1); } }; count(100000)", 0},
+		{"let count = fn(n) { for (x in [1]) { if (n > 0) { return count(n - 1); } } n }; count(100000)", 0},
+		{"let count = fn(n) { if (n > 0) { if (true) { return count(n - 1); } } n }; count(100000)", 0},
+		{"let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; isEven(100000)", true},
+		{"let count = fn(n, step = 1) { if (n <= 0) { n } else { count(n - step) } }; count(100000)", 0},
+		{"let id = fn(x) { x }; let f = fn() { id(5) }; f()", 5},
+		{"let f = fn(n) { n }; return f(3);", 3},
+		{"let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(100)", 5050},
+		{"let f = fn(n) { if (n == 0) { len } else { f(n - 1) } }; f(3)([1, 2])", 2},
+		{"let f = fn(xs) { if (len(xs) == 0) { 0 } else { len(xs) } }; f([1])", 1},
+		{"let g = fn() { 5 }; let f = fn() { for (x in [1]) { if (x == 1) { return g() } } 0 }; f()", 5},
+		{"let f = fn(n) { let r = 0; while (n > 0) { r = r + n; n = n - 1; } r }; let g = fn(n) { f(n) }; g(3)", 6},
+		// errors of calls in tail position are reported at the call
+		{"let f = fn() { g(1) };\nlet g = fn() { 1 };\nf()", Inspect("ERROR: 1:17: wrong number of arguments to `g`. got=1, want=0")},
+		{"let f = fn() {\n  return len(1);\n};\nf()", Inspect("ERROR: 2:13: argument to `len` not supported, got INTEGER")},
+		{"let f = fn() { 5(1) };\nf()", Inspect("ERROR: 1:17: not a function: INTEGER")},
+		{"return 5(1);", Inspect("ERROR: 1:9: not a function: INTEGER")},
+		{"let f = fn(n) { if (n == 0) { 1 / 0 } else { f(n - 1) } };\nf(3)", Inspect("ERROR: 1:33: division by zero: 1 / 0")},
+	}},
+	{"RecursionLimits", []Case{
+		{"let f = fn(n) { 1 + f(n + 1) }; f(0)", Inspect("ERROR: 1:22: maximum recursion depth exceeded: `f` x 10000")},
+		{"let f = fn() { 1 + fn() { 1 + f() }() }; f()", Error("maximum recursion depth exceeded: `f` -> anonymous function -> `f` -> anonymous function -> `f` -> ... -> anonymous function -> `f` -> anonymous function -> `f` -> anonymous function")},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(9999)", 9999},
+		{"let f = fn(n) { if (n == 0) { 0 } else { map([n], fn(x) { f(n - 1) })[0] } }; f(5000)", 0},
+		{"let f = fn(n) { if (n == 0) { 0 } else { reduce([n], fn(acc, x) { acc + f(n - 1) }, 1) } }; f(3000)", 3000},
+		// functions called back by builtins count, even when they return to the builtin in tail position
+		{"let f = fn(x) { map([1], fn(y) { f(x) }) }; f(1)", Inspect("ERROR: 1:20: maximum recursion depth exceeded: `f` x 10000")},
+		{"let f = fn(x) { filter([1], fn(y) { f(x) }) }; f(1)", Inspect("ERROR: 1:23: maximum recursion depth exceeded: `f` x 10000")},
+		{"let f = fn(x) { reduce([1], fn(acc, y) { f(x) }, 0) }; f(1)", Inspect("ERROR: 1:23: maximum recursion depth exceeded: `f` x 10000")},
+		{"let f = fn(x) { sort([1, 2], fn(a, b) { f(x) }) }; f(1)", Inspect("ERROR: 1:21: maximum recursion depth exceeded: `f` x 10000")},
+		{"let f = fn(x) { any([1], f) }; f(1)", Inspect("ERROR: 1:20: maximum recursion depth exceeded: `f` x 10000")},
+		{"let f = fn() { 1 + f() }; f()", Error("maximum recursion depth exceeded: `f` x 10000")},
+	}},
+	{"SelfReferences", []Case{
+		{"let a = [1]; a[0] = a; a", Inspect("[[...]]")},
+		{"let a = [1, 2]; a[1] = [a]; a", Inspect("[1, [[...]]]")},
+		{`let h = {}; h["k"] = h; h`, Inspect("{k: {...}}")},
+		{`let h = {}; h["k"] = [h]; h`, Inspect("{k: [{...}]}")},
+		{"let x = [1]; [x, x]", Inspect("[[1], [1]]")},
+		{"let a = [1]; a[0] = a; len(a[0][0][0])", 1},
+		{"let a = [1, 2]; a[1] = a; let b = [1, 2]; b[1] = b; contains([a], b)", true},
+		{"let a = [1, 2]; a[1] = a; indexOf([[1, 3], a], [1, [1, a]])", 1},
+		{"let a = [1, 2]; a[1] = a; contains([a], [1, [1, 3]])", false},
+		{`let a = [1]; a[0] = a; "${a}"`, Str("[[...]]")},
+	}},
+	{"AssignErrors", []Case{
+		{"x = 5;", Error("assignment to undeclared identifier: x")},
+		{"x += 5;", Error("identifier not found: x")},
+		{"let x = 1; x += true;", Error("type mismatch: INTEGER + BOOLEAN")},
+		{"let arr = [1]; arr[1] = 2;", Error("index out of range: 1")},
+		{`let h = {}; h[fn() {}] = 2;`, Error("unusable as hash key: FUNCTION")},
+		{"let x = 1; x[0] = 2;", Error("index assignment not supported: INTEGER")},
+	}},
+}
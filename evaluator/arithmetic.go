@@ -0,0 +1,71 @@
+package evaluator
+
+import "math"
+
+// integerOperations maps arithmetic operators to their overflow-checked implementation.
+// Every operation reports false if the result doesn't fit into an int64.
+var integerOperations = map[string]func(a, b int64) (int64, bool){
+	"+": addInt64,
+	"-": subInt64,
+	"*": mulInt64,
+	"^": powInt64,
+}
+
+func addInt64(a, b int64) (int64, bool) {
+	c := a + b
+	// the sum overflowed if it moved in the opposite direction of b
+	return c, (c > a) == (b > 0)
+}
+
+func subInt64(a, b int64) (int64, bool) {
+	c := a - b
+	// the difference overflowed if it moved in the same direction as b
+	return c, (c < a) == (b > 0)
+}
+
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+
+	// MinInt64 * -1 can't be detected by the division below, since MinInt64 / -1 overflows as well
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+
+	c := a * b
+	return c, c/b == a
+}
+
+func divInt64(a, b int64) (int64, bool) {
+	// MinInt64 / -1 is the only division which overflows
+	if a == math.MinInt64 && b == -1 {
+		return 0, false
+	}
+	return a / b, true
+}
+
+// powInt64 computes base^exp for a non-negative exp by repeated squaring.
+func powInt64(base, exp int64) (int64, bool) {
+	result := int64(1)
+
+	for exp > 0 {
+		var ok bool
+
+		if exp&1 == 1 {
+			if result, ok = mulInt64(result, base); !ok {
+				return 0, false
+			}
+		}
+
+		exp >>= 1
+		// the base is only squared if it is still needed, so a large last square can't overflow
+		if exp > 0 {
+			if base, ok = mulInt64(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+
+	return result, true
+}
@@ -0,0 +1,313 @@
+package evaluator
+
+import (
+	"math"
+	"waixg/interpreter/object"
+)
+
+// The array builtins never modify their arguments, arrays are always returned as new arrays.
+
+// arrayArg returns the elements of args[0] after checking that it is the only argument and an array
+func arrayArg(name string, args []object.Object) ([]object.Object, *object.Error) {
+	if err := checkArgs(name, args, object.ArrayObj); err != nil {
+		return nil, err
+	}
+
+	return args[0].(*object.Array).Elements, nil
+}
+
+// builtinFirst returns the first element of an array, or null if it is empty
+func builtinFirst(ctx object.Context, args ...object.Object) object.Object {
+	elements, err := arrayArg("first", args)
+	if err != nil {
+		return err
+	}
+
+	if len(elements) == 0 {
+		return NULL
+	}
+	return elements[0]
+}
+
+// builtinLast returns the last element of an array, or null if it is empty
+func builtinLast(ctx object.Context, args ...object.Object) object.Object {
+	elements, err := arrayArg("last", args)
+	if err != nil {
+		return err
+	}
+
+	if len(elements) == 0 {
+		return NULL
+	}
+	return elements[len(elements)-1]
+}
+
+// builtinRest returns an array of all elements but the first, or null if the array is empty
+func builtinRest(ctx object.Context, args ...object.Object) object.Object {
+	elements, err := arrayArg("rest", args)
+	if err != nil {
+		return err
+	}
+
+	if len(elements) == 0 {
+		return NULL
+	}
+	return &object.Array{Elements: copyElements(elements[1:])}
+}
+
+// builtinPush returns an array of the elements of an array followed by the given values
+func builtinPush(ctx object.Context, args ...object.Object) object.Object {
+	if len(args) < 2 {
+		return newError("wrong number of arguments. got=%d, want at least 2", len(args))
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument to `push` not supported, got %s", args[0].Type())
+	}
+
+	elements := make([]object.Object, 0, len(array.Elements)+len(args)-1)
+	elements = append(elements, array.Elements...)
+	elements = append(elements, args[1:]...)
+	return &object.Array{Elements: elements}
+}
+
+// builtinPop returns an array of all elements but the last, or null if the array is empty
+func builtinPop(ctx object.Context, args ...object.Object) object.Object {
+	elements, err := arrayArg("pop", args)
+	if err != nil {
+		return err
+	}
+
+	if len(elements) == 0 {
+		return NULL
+	}
+	return &object.Array{Elements: copyElements(elements[:len(elements)-1])}
+}
+
+// builtinSlice returns an array of the elements from start up to, but not including, end or the end of
+// the array. Like indexing, negative positions count from the end. The slice is cut off at the ends
+// of the array.
+func builtinSlice(ctx object.Context, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+	types := []object.ObjectType{object.ArrayObj, object.IntegerObj, object.IntegerObj}
+	if err := checkArgs("slice", args, types[:len(args)]...); err != nil {
+		return err
+	}
+
+	elements := args[0].(*object.Array).Elements
+	length := int64(len(elements))
+
+	start := clampIndex(args[1].(*object.Integer).Value, length)
+	end := length
+	if len(args) == 3 {
+		end = clampIndex(args[2].(*object.Integer).Value, length)
+	}
+	if end < start {
+		end = start
+	}
+
+	return &object.Array{Elements: copyElements(elements[start:end])}
+}
+
+// clampIndex resolves a negative index from the end and cuts it off at the ends of a sequence of length
+func clampIndex(index int64, length int64) int64 {
+	if index < 0 {
+		index += length
+	}
+	if index < 0 {
+		return 0
+	}
+	if index > length {
+		return length
+	}
+	return index
+}
+
+// builtinConcat returns an array of the elements of all the given arrays
+func builtinConcat(ctx object.Context, args ...object.Object) object.Object {
+	length := 0
+	for _, arg := range args {
+		array, ok := arg.(*object.Array)
+		if !ok {
+			return newError("argument to `concat` not supported, got %s", arg.Type())
+		}
+		length += len(array.Elements)
+	}
+	if err := reserve(ctx, 1+length); err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, 0, length)
+	for _, arg := range args {
+		elements = append(elements, arg.(*object.Array).Elements...)
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// builtinReverse returns an array of the elements of an array in reverse order
+func builtinReverse(ctx object.Context, args ...object.Object) object.Object {
+	elements, err := arrayArg("reverse", args)
+	if err != nil {
+		return err
+	}
+
+	reversed := make([]object.Object, len(elements))
+	for i, el := range elements {
+		reversed[len(elements)-1-i] = el
+	}
+	return &object.Array{Elements: reversed}
+}
+
+// builtinRange returns an array of the integers from start up to, but not including, end, counting by step.
+// It is called as range(end), range(start, end) or range(start, end, step), start defaults to 0 and
+// step to 1. A negative step counts down.
+func builtinRange(ctx object.Context, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+	}
+	for _, arg := range args {
+		if arg.Type() != object.IntegerObj {
+			return newError("argument to `range` not supported, got %s", arg.Type())
+		}
+	}
+
+	start, end, step := int64(0), args[0].(*object.Integer).Value, int64(1)
+	if len(args) > 1 {
+		start, end = end, args[1].(*object.Integer).Value
+	}
+	if len(args) > 2 {
+		step = args[2].(*object.Integer).Value
+	}
+	if step == 0 {
+		return newError("zero step in `range`")
+	}
+	if err := reserve(ctx, 1+rangeLength(start, end, step)); err != nil {
+		return err
+	}
+
+	elements := []object.Object{}
+	for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+		if err := tick(ctx); err != nil {
+			return err
+		}
+		elements = append(elements, &object.Integer{Value: i})
+
+		// stop before i overflows
+		if (step > 0 && i > math.MaxInt64-step) || (step < 0 && i < math.MinInt64-step) {
+			break
+		}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// rangeLength returns the number of integers range yields for start, end and step,
+// capped so that one more still fits into an int
+func rangeLength(start, end, step int64) int {
+	// the distances are computed unsigned, since they can exceed math.MaxInt64
+	var distance, stride uint64
+	switch {
+	case step > 0 && start < end:
+		distance, stride = uint64(end)-uint64(start), uint64(step)
+	case step < 0 && start > end:
+		distance, stride = uint64(start)-uint64(end), -uint64(step)
+	default:
+		return 0
+	}
+
+	n := (distance-1)/stride + 1
+	if n >= math.MaxInt {
+		return math.MaxInt - 1
+	}
+	return int(n)
+}
+
+// builtinZip returns an array of arrays, the first one of the first elements of all the given arrays and
+// so on. It is as long as the shortest array.
+func builtinZip(ctx object.Context, args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want at least 1")
+	}
+
+	length := -1
+	for _, arg := range args {
+		array, ok := arg.(*object.Array)
+		if !ok {
+			return newError("argument to `zip` not supported, got %s", arg.Type())
+		}
+		if length < 0 || len(array.Elements) < length {
+			length = len(array.Elements)
+		}
+	}
+
+	tuples := make([]object.Object, length)
+	for i := range tuples {
+		tuple := make([]object.Object, len(args))
+		for j, arg := range args {
+			tuple[j] = arg.(*object.Array).Elements[i]
+		}
+		tuples[i] = &object.Array{Elements: tuple}
+	}
+	return &object.Array{Elements: tuples}
+}
+
+// arrayIndexOf returns the position of the first element of array equal to value, or -1 if there is none
+func arrayIndexOf(array *object.Array, value object.Object) int {
+	for i, el := range array.Elements {
+		if objectsEqual(el, value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// objectsEqual reports whether two values are equal: numbers, strings, booleans and null by value,
+// arrays element by element and everything else by identity
+func objectsEqual(a, b object.Object) bool {
+	return objectsEqualSeen(a, b, map[[2]*object.Array]bool{})
+}
+
+// objectsEqualSeen compares a and b inside the pairs of arrays in seen.
+// Arrays containing themselves are equal if comparing them again doesn't find a difference.
+func objectsEqualSeen(a, b object.Object, seen map[[2]*object.Array]bool) bool {
+	if isNumber(a) && isNumber(b) {
+		// like ==, so 1 equals 1.0
+		return evalInfixExpression("==", a, b) == TRUE
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *object.String:
+		return a.Value == b.(*object.String).Value
+	case *object.Array:
+		other := b.(*object.Array)
+		if len(a.Elements) != len(other.Elements) {
+			return false
+		}
+		pair := [2]*object.Array{a, other}
+		if seen[pair] {
+			return true
+		}
+		seen[pair] = true
+		defer delete(seen, pair)
+
+		for i, el := range a.Elements {
+			if !objectsEqualSeen(el, other.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// copyElements returns a copy of elements, so the array returned by a builtin doesn't share them with
+// its argument
+func copyElements(elements []object.Object) []object.Object {
+	return append([]object.Object{}, elements...)
+}
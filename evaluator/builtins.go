@@ -1,20 +1,177 @@
 package evaluator
 
-import "waixg/interpreter/object"
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+	"waixg/interpreter/object"
+)
 
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				// the length of a string is its number of characters, bytes() gives access to the bytes
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *object.Array:
+				return &object.Integer{Value: int64(len(arg.Elements))}
 			default:
 				return newError("argument to `len` not supported, got %s", args[0].Type())
 			}
 		},
 	},
+	"bytes": &object.Builtin{
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `bytes` not supported, got %s", args[0].Type())
+			}
+
+			elements := make([]object.Object, len(str.Value))
+			for i := 0; i < len(str.Value); i++ {
+				elements[i] = &object.Integer{Value: int64(str.Value[i])}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"puts": &object.Builtin{
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			for _, arg := range args {
+				_, _ = fmt.Fprintln(ctx.Output(), arg.Inspect())
+			}
+
+			return NULL
+		},
+	},
+	"int": &object.Builtin{
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				// the fractional part is truncated, values outside the int64 range can't be converted
+				if math.IsNaN(arg.Value) || arg.Value < math.MinInt64 || arg.Value >= math.MaxInt64 {
+					return newError("cannot convert %s to INTEGER", arg.Inspect())
+				}
+				return &object.Integer{Value: int64(arg.Value)}
+			case *object.String:
+				value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 0, 64)
+				if err != nil {
+					return newError("cannot convert %q to INTEGER", arg.Value)
+				}
+				return &object.Integer{Value: value}
+			default:
+				return newError("argument to `int` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"float": &object.Builtin{
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return &object.Float{Value: float64(arg.Value)}
+			case *object.Float:
+				return arg
+			case *object.String:
+				value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return newError("cannot convert %q to FLOAT", arg.Value)
+				}
+				return &object.Float{Value: value}
+			default:
+				return newError("argument to `float` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"idiv":       &object.Builtin{Fn: builtinIdiv},
+	"split":      &object.Builtin{Fn: builtinSplit},
+	"join":       &object.Builtin{Fn: builtinJoin},
+	"trim":       stringFunction("trim", strings.TrimSpace),
+	"upper":      stringFunction("upper", strings.ToUpper),
+	"lower":      stringFunction("lower", strings.ToLower),
+	"replace":    &object.Builtin{Fn: builtinReplace},
+	"contains":   &object.Builtin{Fn: builtinContains},
+	"startsWith": stringPredicate("startsWith", strings.HasPrefix),
+	"endsWith":   stringPredicate("endsWith", strings.HasSuffix),
+	"indexOf":    &object.Builtin{Fn: builtinIndexOf},
+	"substr":     &object.Builtin{Fn: builtinSubstr},
+	"repeat":     &object.Builtin{Fn: builtinRepeat},
+	"format":     formatFunction("format"),
+	"sprintf":    formatFunction("sprintf"),
+	"first":      &object.Builtin{Fn: builtinFirst},
+	"last":       &object.Builtin{Fn: builtinLast},
+	"rest":       &object.Builtin{Fn: builtinRest},
+	"push":       &object.Builtin{Fn: builtinPush},
+	"pop":        &object.Builtin{Fn: builtinPop},
+	"slice":      &object.Builtin{Fn: builtinSlice},
+	"concat":     &object.Builtin{Fn: builtinConcat},
+	"reverse":    &object.Builtin{Fn: builtinReverse},
+	"range":      &object.Builtin{Fn: builtinRange},
+	"zip":        &object.Builtin{Fn: builtinZip},
+	"map":        &object.Builtin{Fn: builtinMap},
+	"filter":     &object.Builtin{Fn: builtinFilter},
+	"reduce":     &object.Builtin{Fn: builtinReduce},
+	"sort":       &object.Builtin{Fn: builtinSort},
+	"any":        &object.Builtin{Fn: builtinAny},
+	"all":        &object.Builtin{Fn: builtinAll},
+	"groupBy":    &object.Builtin{Fn: builtinGroupBy},
+}
+
+// DefineBuiltin makes builtin available under name to programs evaluated in env and every environment
+// enclosed by it. Like the builtins of the language, it can be shadowed by variables and it replaces a
+// builtin of the language with the same name.
+func DefineBuiltin(env *object.Environment, name string, builtin *object.Builtin) {
+	state := stateOf(env)
+	if state.builtins == nil {
+		state.builtins = make(map[string]*object.Builtin)
+	}
+	state.builtins[name] = builtin
+}
+
+// builtinIdiv divides two numbers and truncates the quotient towards zero, like / does for two integers.
+// The language has no operator for it: // starts a comment and / keeps the fraction of a float.
+func builtinIdiv(ctx object.Context, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	for _, arg := range args {
+		if arg.Type() != object.IntegerObj && arg.Type() != object.FloatObj {
+			return newError("argument to `idiv` not supported, got %s", arg.Type())
+		}
+	}
+
+	left, right := args[0], args[1]
+	if a, ok := left.(*object.Integer); ok {
+		if b, ok := right.(*object.Integer); ok {
+			return evalIntegerInfixExpression("/", a, b)
+		}
+	}
+
+	if toFloat(right) == 0 {
+		return newError("division by zero: %s / %s", left.Inspect(), right.Inspect())
+	}
+	quotient := math.Trunc(toFloat(left) / toFloat(right))
+	if math.IsNaN(quotient) || quotient < math.MinInt64 || quotient >= math.MaxInt64 {
+		return newError("cannot convert %s to INTEGER", (&object.Float{Value: quotient}).Inspect())
+	}
+	return &object.Integer{Value: int64(quotient)}
 }
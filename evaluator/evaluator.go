@@ -3,6 +3,8 @@ package evaluator
 import (
 	"fmt"
 	"math"
+	"strings"
+	"unicode/utf8"
 	"waixg/interpreter/ast"
 	"waixg/interpreter/object"
 )
@@ -13,7 +15,43 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+// Eval evaluates node in env.
+// Every evaluated node counts as a step against the budgets of the evaluation, see Options.
+// The budgets start over with every program, e.g. with every input of a REPL.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	state := stateOf(env)
+	if _, ok := node.(*ast.Program); ok {
+		state.resetBudgets()
+	}
+	if err := state.step(); err != nil {
+		return locateError(err, node)
+	}
+
+	result := eval(node, env)
+
+	if allocatesResult(node) {
+		if err := state.allocate(result); err != nil {
+			return locateError(err, node)
+		}
+	}
+
+	return result
+}
+
+// allocatesResult reports whether node evaluates to a newly allocated object
+func allocatesResult(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.InterpolatedString, *ast.ArrayLiteral,
+		*ast.HashLiteral, *ast.FunctionLiteral, *ast.PrefixExpression, *ast.SliceExpression:
+		return true
+	case *ast.InfixExpression:
+		// the logical operators evaluate to one of their operands
+		return !isLogicalOperator(node.Operator)
+	}
+	return false
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Statements
 	case *ast.Program:
@@ -26,12 +64,25 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalBlockStatement(node, env)
 
 	case *ast.ReturnStatement:
+		// returns in tail position of a function are evaluated by evalTail instead
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env, Eval)
+
+	case *ast.ForStatement:
+		return locateError(evalForStatement(node, env, Eval), node)
+
+	case *ast.BreakStatement:
+		return &object.Break{Pos: node.Pos()}
+
+	case *ast.ContinueStatement:
+		return &object.Continue{Pos: node.Pos()}
+
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
@@ -43,16 +94,27 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 
 	case *ast.FunctionLiteral:
-		params := node.Parameters
-		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{
+			Name:       node.Name,
+			Parameters: node.Parameters,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
+			Body:       node.Body,
+			Env:        env,
+		}
 
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
@@ -64,16 +126,19 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return locateError(applyFunction(stateOf(env), function, args), node)
 
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return locateError(evalPrefixExpression(node.Operator, right), node)
 
 	case *ast.InfixExpression:
+		if isLogicalOperator(node.Operator) {
+			return evalLogicalExpression(node, env)
+		}
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -82,11 +147,38 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return locateError(evalInfixExpression(node.Operator, left, right), node)
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+
+	case *ast.AssignExpression:
+		return locateError(evalAssignExpression(node, env), node)
+
+	case *ast.HashLiteral:
+		return locateError(evalHashLiteral(node, env), node)
+
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return locateError(evalIndexExpression(left, index), node)
+
+	case *ast.SliceExpression:
+		return locateError(evalSliceNode(node, env), node)
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return locateError(evalIdentifier(node, env), node)
 
 	default:
 		return NULL
@@ -94,14 +186,66 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	return nil
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// applyFunction calls fn with args in the evaluation with the given state.
+// Calls in tail position are handed back by the called function as a tailCall and applied here in a
+// loop (a trampoline), instead of recursing into Eval.
+// Every applyFunction takes one level of the recursion depth until it returns, the calls in tail position
+// take the same level. So the depth also grows when a builtin calls back a function which tail-calls the
+// builtin again.
+func applyFunction(state *evalState, fn object.Object, args []object.Object) object.Object {
+	level := len(state.calls)
+	defer state.leaveCalls(level)
+
+	var call *ast.CallExpression
+
+	for {
+		result := callFunction(state, level, fn, args)
+		if call != nil {
+			// the caller only knows its own call, errors of tail calls point to the tail call itself
+			result = locateError(result, call)
+		}
+
+		tc, ok := result.(*tailCall)
+		if !ok {
+			return result
+		}
+		fn, args, call = tc.fn, tc.args, tc.node
+	}
+}
+
+// callFunction calls fn with args at the given level of nesting, but returns a call in tail position of
+// fn as a tailCall
+func callFunction(state *evalState, level int, fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		if err := checkArity(fn, args); err != nil {
+			return err
+		}
+
+		if err := state.enterCall(level, fn); err != nil {
+			return err
+		}
+
+		// the environment of the call holds the arguments
+		if err := state.charge(1 + len(args)); err != nil {
+			return err
+		}
+
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+		evaluated := evalTail(fn.Body, extendedEnv)
+		if err := loopSignalError(evaluated); err != nil {
+			return err
+		}
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return fn.Fn(args...)
+		result := fn.Fn(state, args...)
+		if err := state.allocate(result); err != nil {
+			return err
+		}
+		return result
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
@@ -114,14 +258,64 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// checkArity returns an error if fn can't be called with the given arguments
+func checkArity(fn *object.Function, args []object.Object) *object.Error {
+	required := len(fn.Parameters) - len(fn.Defaults)
+
+	var want string
+	switch {
+	case fn.Rest != nil:
+		want = fmt.Sprintf("at least %d", required)
+	case required != len(fn.Parameters):
+		want = fmt.Sprintf("%d to %d", required, len(fn.Parameters))
+	default:
+		want = fmt.Sprintf("%d", required)
+	}
+
+	if len(args) < required || (fn.Rest == nil && len(args) > len(fn.Parameters)) {
+		return newError("wrong number of arguments to %s. got=%d, want=%s", functionName(fn), len(args), want)
+	}
+
+	return nil
+}
+
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "anonymous function"
+	}
+	return "`" + fn.Name + "`"
+}
+
+// extendFunctionEnv binds the arguments to the parameters of fn in a new environment enclosed by the
+// environment fn was defined in.
+// Missing optional arguments are filled in with their default values, which are evaluated in the new
+// environment, so they can refer to the parameters before them.
+// Arguments exceeding the parameters are collected in an array bound to the rest parameter.
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+
+		value := Eval(fn.Defaults[param.Value], env)
+		if isError(value) {
+			return nil, value
+		}
+		env.Set(param.Value, value)
 	}
 
-	return env
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	return env, nil
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
@@ -142,6 +336,10 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return val
 	}
 
+	if builtin, ok := stateOf(env).builtins[node.Value]; ok {
+		return builtin
+	}
+
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
@@ -153,6 +351,15 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Err: fmt.Errorf(format, a...)}
 }
 
+// locateError attaches the source position of node to obj if it is an error without a position.
+// Errors raised deeper in the tree already carry a more precise position and are left alone.
+func locateError(obj object.Object, node ast.Node) object.Object {
+	if err, ok := obj.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+	return obj
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ErrorObj
@@ -168,14 +375,18 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 
 		if result != nil {
 			rt := result.Type()
-			// If the result is either a return statement or an error, we return it
+			// If the result is a return statement, an error or a loop signal, we return it
 			// and stop evaluating the rest of the statements
-			if rt == object.ReturnValueObj || rt == object.ErrorObj {
+			if rt == object.ReturnValueObj || rt == object.ErrorObj || rt == object.BreakObj || rt == object.ContinueObj {
 				return result
 			}
 		}
 
 	}
+	if result == nil {
+		// An empty block, or one ending in a statement without a value, evaluates to null
+		return NULL
+	}
 	return result
 }
 
@@ -193,6 +404,91 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 }
 
+// evalWhileStatement runs the body as long as the condition is truthy.
+// The body is evaluated with evalBody, which keeps returns in tail position when the loop is in a function body.
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment, evalBody evalFunc) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := evalBody(ws.Body, env)
+		if done, result := evalLoopResult(result); done {
+			return result
+		}
+	}
+}
+
+// evalForStatement runs the body once per element of an array, per character of a string
+// or per key of a hash (in no particular order).
+// Every iteration gets its own environment holding the loop variable. The body is evaluated with evalBody,
+// like in evalWhileStatement.
+func evalForStatement(fs *ast.ForStatement, env *object.Environment, evalBody evalFunc) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	var elements []object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		elements = iterable.Elements
+	case *object.String:
+		for _, ch := range iterable.Value {
+			elements = append(elements, &object.String{Value: string(ch)})
+		}
+	case *object.Hash:
+		for _, pair := range iterable.Ordered() {
+			elements = append(elements, pair.Key)
+		}
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	for _, element := range elements {
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.Set(fs.Variable.Value, element)
+
+		result := evalBody(fs.Body, loopEnv)
+		if done, result := evalLoopResult(result); done {
+			return result
+		}
+	}
+
+	return NULL
+}
+
+// evalLoopResult decides what happens after the body of a loop has been evaluated.
+// It reports whether the loop is done and, if so, the value the loop evaluates to.
+func evalLoopResult(result object.Object) (bool, object.Object) {
+	switch result.(type) {
+	case *object.Break:
+		return true, NULL
+	case *object.ReturnValue, *object.Error:
+		// return statements and errors leave the loop and are propagated to the caller
+		return true, result
+	default:
+		// a continue or the regular end of the body starts the next iteration
+		return false, nil
+	}
+}
+
+// loopSignalError returns an error at the statement if a break or continue escaped every loop, nil otherwise
+func loopSignalError(obj object.Object) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Break:
+		return &object.Error{Err: fmt.Errorf("break outside of loop"), Pos: obj.Pos}
+	case *object.Continue:
+		return &object.Error{Err: fmt.Errorf("continue outside of loop"), Pos: obj.Pos}
+	default:
+		return nil
+	}
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	// Handle the constant objects
@@ -212,6 +508,9 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 	switch {
 	case left.Type() == object.IntegerObj && right.Type() == object.IntegerObj:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		// at least one side is a float, so the other one is converted to a float as well
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.BooleanObj && right.Type() == object.BooleanObj:
 		return evalBooleanInfixExpression(operator, left, right)
 	case left.Type() == object.StringObj && right.Type() == object.StringObj:
@@ -223,6 +522,27 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 	}
 }
 
+// isLogicalOperator reports whether operator is && or ||, whose right operand is only evaluated if needed
+func isLogicalOperator(operator string) bool {
+	return operator == "&&" || operator == "||"
+}
+
+// evalLogicalExpression evaluates && and || with short-circuiting. Like in most dynamic languages they
+// evaluate to the operand that decides the result: && to its left operand if that is not truthy and ||
+// to its left operand if that is truthy, without evaluating the right one. Otherwise they evaluate to
+// the right operand.
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if isTruthy(left) == (node.Operator == "||") {
+		return left
+	}
+
+	return Eval(node.Right, env)
+}
+
 func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	if operator != "+" {
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
@@ -234,6 +554,33 @@ func evalStringInfixExpression(operator string, left object.Object, right object
 	return &object.String{Value: leftVal + rightVal}
 }
 
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	values := make([]object.Object, len(node.Parts))
+	for i, part := range node.Parts {
+		value := Eval(part, env)
+		if isError(value) {
+			return value
+		}
+		if value == nil {
+			// e.g. a call of a function ending with a let statement
+			value = NULL
+		}
+		values[i] = value
+	}
+
+	return Interpolate(values)
+}
+
+// Interpolate joins the values of the parts of an interpolated string, each one the way it is inspected
+func Interpolate(values []object.Object) *object.String {
+	var out strings.Builder
+	for _, value := range values {
+		out.WriteString(value.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 func evalBooleanInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	// we use pointer comparisons here since true or false will always point to the same object regardless
 	switch operator {
@@ -250,18 +597,98 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
 
+	switch operator {
+	// Mathematical Operations
+	case "+", "-", "*", "^":
+		// a negative exponent results in a fraction, which can't be represented as an integer
+		if operator == "^" && rightVal < 0 {
+			return &object.Float{Value: math.Pow(float64(leftVal), float64(rightVal))}
+		}
+
+		result, ok := integerOperations[operator](leftVal, rightVal)
+		if !ok {
+			return newError("integer overflow: %d %s %d", leftVal, operator, rightVal)
+		}
+		return &object.Integer{Value: result}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %d / %d", leftVal, rightVal)
+		}
+
+		result, ok := divInt64(leftVal, rightVal)
+		if !ok {
+			return newError("integer overflow: %d / %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: result}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero: %d %% %d", leftVal, rightVal)
+		}
+		// like the quotient of / is truncated towards zero, the remainder has the sign of leftVal
+		return &object.Integer{Value: leftVal % rightVal}
+
+	// Bitwise Operations
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "~":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<", ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d %s %d", leftVal, operator, rightVal)
+		}
+		// like in Go, bits shifted out are lost and >> keeps the sign
+		if operator == "<<" {
+			return &object.Integer{Value: leftVal << rightVal}
+		}
+		return &object.Integer{Value: leftVal >> rightVal}
+
+	// Comparison Operations
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+
+	// fallthrough
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+
+	}
+}
+
+func evalFloatInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
 	switch operator {
 	// Mathematical Operations
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return &object.Float{Value: leftVal + rightVal}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return &object.Float{Value: leftVal - rightVal}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		return &object.Float{Value: leftVal * rightVal}
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		if rightVal == 0 {
+			return newError("division by zero: %s / %s", left.Inspect(), right.Inspect())
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero: %s %% %s", left.Inspect(), right.Inspect())
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
 	case "^":
-		return &object.Integer{Value: int64(math.Pow(float64(leftVal), float64(rightVal)))}
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
 
 	// Comparison Operations
 	case "<":
@@ -280,7 +707,22 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	// fallthrough
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
 
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.IntegerObj || obj.Type() == object.FloatObj
+}
+
+// toFloat returns the value of an Integer or Float object as float64.
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	default:
+		return math.NaN()
 	}
 }
 
@@ -290,17 +732,34 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalBitwiseNotOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.IntegerObj {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return newError("integer overflow: -(%d)", right.Value)
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+}
+
+// evalBitwiseNotOperatorExpression flips all bits of an integer
+func evalBitwiseNotOperatorExpression(right object.Object) object.Object {
+	integer, ok := right.(*object.Integer)
+	if !ok {
+		return newError("unknown operator: ~%s", right.Type())
+	}
+	return &object.Integer{Value: ^integer.Value}
 }
 
 func evalBangOperatorExpression(right object.Object) object.Object {
@@ -323,6 +782,308 @@ func nativeBoolToBooleanObject(value bool) object.Object {
 	return FALSE
 }
 
+func evalIndexExpression(left object.Object, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ArrayObj && index.Type() == object.IntegerObj:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.StringObj && index.Type() == object.IntegerObj:
+		return evalStringIndexExpression(left, index)
+	case left.Type() == object.HashObj:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+// evalArrayIndexExpression looks up a single element of an array.
+// Negative indices count from the end of the array, so -1 is the last element.
+// Indices outside the array evaluate to NULL.
+func evalArrayIndexExpression(array object.Object, index object.Object) object.Object {
+	elements := array.(*object.Array).Elements
+	idx := index.(*object.Integer).Value
+	length := int64(len(elements))
+
+	if idx < 0 {
+		idx += length
+	}
+
+	if idx < 0 || idx >= length {
+		return NULL
+	}
+
+	return elements[idx]
+}
+
+// evalStringIndexExpression looks up a single character of a string, counting characters rather than bytes.
+// Like for arrays, negative indices count from the end and indices outside the string evaluate to NULL.
+func evalStringIndexExpression(str object.Object, index object.Object) object.Object {
+	chars := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+	length := int64(len(chars))
+
+	if idx < 0 {
+		idx += length
+	}
+
+	if idx < 0 || idx >= length {
+		return NULL
+	}
+
+	return &object.String{Value: string(chars[idx])}
+}
+
+func evalHashIndexExpression(hash object.Object, index object.Object) object.Object {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
+
+func evalSliceNode(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	// the parts that are left out are null, like in the bytecode
+	bounds := []object.Object{NULL, NULL, NULL}
+	for i, exp := range []ast.Expression{node.Start, node.End, node.Step} {
+		if exp == nil {
+			continue
+		}
+		bounds[i] = Eval(exp, env)
+		if isError(bounds[i]) {
+			return bounds[i]
+		}
+	}
+
+	return evalSliceExpression(left, bounds[0], bounds[1], bounds[2])
+}
+
+// evalSliceExpression takes the elements of an array or the characters of a string from start up to,
+// but not including, end, every step elements. Like in Python, null bounds are left out, negative ones
+// count from the end and bounds outside of the array are cut off at its ends.
+// A negative step walks backwards, from the end if there is no start.
+func evalSliceExpression(left, start, end, step object.Object) object.Object {
+	var length int64
+	switch left := left.(type) {
+	case *object.Array:
+		length = int64(len(left.Elements))
+	case *object.String:
+		length = int64(utf8.RuneCountInString(left.Value))
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	indices, err := sliceIndices(length, start, end, step)
+	if err != nil {
+		return err
+	}
+
+	if array, ok := left.(*object.Array); ok {
+		elements := make([]object.Object, len(indices))
+		for i, idx := range indices {
+			elements[i] = array.Elements[idx]
+		}
+		return &object.Array{Elements: elements}
+	}
+
+	chars := []rune(left.(*object.String).Value)
+	sliced := make([]rune, len(indices))
+	for i, idx := range indices {
+		sliced[i] = chars[idx]
+	}
+	return &object.String{Value: string(sliced)}
+}
+
+// sliceIndices returns the positions of the elements of a slice of a sequence with length elements
+func sliceIndices(length int64, start, end, step object.Object) ([]int64, *object.Error) {
+	values := []int64{0, 0, 1}
+	for i, bound := range []object.Object{start, end, step} {
+		switch bound := bound.(type) {
+		case *object.Null:
+		case *object.Integer:
+			values[i] = bound.Value
+		default:
+			return nil, newError("slice index must be INTEGER, got %s", bound.Type())
+		}
+	}
+
+	stepValue := values[2]
+	if stepValue == 0 {
+		return nil, newError("slice step cannot be zero")
+	}
+
+	// the range of the bounds, a negative step can stop before the first element
+	lower, upper := int64(0), length
+	if stepValue < 0 {
+		lower, upper = -1, length-1
+	}
+
+	from, to := lower, upper
+	if stepValue < 0 {
+		from, to = upper, lower
+	}
+	if start != NULL {
+		from = clampSliceBound(values[0], length, lower, upper)
+	}
+	if end != NULL {
+		to = clampSliceBound(values[1], length, lower, upper)
+	}
+
+	indices := []int64{}
+	for i := from; (stepValue > 0 && i < to) || (stepValue < 0 && i > to); i += stepValue {
+		indices = append(indices, i)
+
+		// stop before i overflows
+		if (stepValue > 0 && i > math.MaxInt64-stepValue) || (stepValue < 0 && i < math.MinInt64-stepValue) {
+			break
+		}
+	}
+	return indices, nil
+}
+
+// clampSliceBound resolves a negative bound from the end and cuts it off at lower and upper
+func clampSliceBound(bound, length, lower, upper int64) int64 {
+	if bound < 0 {
+		bound += length
+	}
+	if bound < lower {
+		return lower
+	}
+	if bound > upper {
+		return upper
+	}
+	return bound
+}
+
+// evalAssignExpression updates an existing variable or an element of an array or hash.
+// Compound operators like += combine the current value with the new one before storing it.
+// The assignment evaluates to the stored value.
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		value := Eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		if node.Operator != "=" {
+			current, ok := env.Get(target.Value)
+			if !ok {
+				return newError("identifier not found: " + target.Value)
+			}
+			value = evalInfixExpression(strings.TrimSuffix(node.Operator, "="), current, value)
+			if isError(value) {
+				return value
+			}
+		}
+
+		if !env.Assign(target.Value, value) {
+			return newError("assignment to undeclared identifier: %s", target.Value)
+		}
+		return value
+
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		value := Eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		if node.Operator != "=" {
+			current := evalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+			value = evalInfixExpression(strings.TrimSuffix(node.Operator, "="), current, value)
+			if isError(value) {
+				return value
+			}
+		}
+
+		return evalIndexAssignment(left, index, value)
+
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
+}
+
+// evalIndexAssignment stores value in an array or hash, modifying it in place.
+func evalIndexAssignment(left object.Object, index object.Object, value object.Object) object.Object {
+	switch {
+	case left.Type() == object.ArrayObj && index.Type() == object.IntegerObj:
+		elements := left.(*object.Array).Elements
+		idx := index.(*object.Integer).Value
+		length := int64(len(elements))
+
+		if idx < 0 {
+			idx += length
+		}
+
+		if idx < 0 || idx >= length {
+			return newError("index out of range: %d", index.(*object.Integer).Value)
+		}
+
+		elements[idx] = value
+		return value
+
+	case left.Type() == object.HashObj:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+
+		left.(*object.Hash).Set(key.HashKey(), object.HashPair{Key: index, Value: value})
+		return value
+
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	// like the compiled literal, the pairs are evaluated in source order
+	for _, keyNode := range node.Keys {
+		key := Eval(keyNode, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(node.Pairs[keyNode], env)
+		if isError(value) {
+			return value
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash
+}
+
 func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
@@ -334,6 +1095,8 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 			return result.Value
 		case *object.Error:
 			return result
+		case *object.Break, *object.Continue:
+			return loopSignalError(result)
 		}
 	}
 
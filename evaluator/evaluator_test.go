@@ -1,41 +1,22 @@
 package evaluator
 
 import (
+	"context"
+	"errors"
+	"math"
 	"testing"
+	"time"
+	"waixg/enginetest"
+	"waixg/interpreter/ast"
 	"waixg/interpreter/lexer"
 	"waixg/interpreter/object"
 	"waixg/interpreter/parser"
 )
 
-func TestEvalIntegerExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"5", 5},
-		{"10", 10},
-		{"-5", -5},
-		{"-10", -10},
-		{"5 + 5 + 5 + 5 - 10", 10},
-		{"2 * 2 * 2 * 2 * 2", 32},
-		{"-50 + 100 + -50", 0},
-		{"5 * 2 + 10", 20},
-		{"5 + 2 * 10", 25},
-		{"20 + 2 * -10", 0},
-		{"50 / 2 * 2 + 10", 60},
-		{"2 * (5 + 10)", 30},
-		{"3 * 3 * 3 + 10", 37},
-		{"3 * (3 * 3) + 10", 37},
-		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
-		{"5^2", 25},
-		{"5^2^2", 625},
-		{"5*3^2^2", 405},
-	}
-
-	for i, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, i, evaluated, tt.expected)
-	}
+func TestScenarios(t *testing.T) {
+	enginetest.Run(t, func(program *ast.Program) object.Object {
+		return Eval(program, object.NewEnvironment())
+	})
 }
 
 func testEval(input string) object.Object {
@@ -61,344 +42,275 @@ func testIntegerObject(t *testing.T, id int, obj object.Object, expected int64)
 	return true
 }
 
-func TestEvalBooleanExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected bool
-	}{
-		{"true", true},
-		{"false", false},
-		{"1 < 2", true},
-		{"1 > 2", false},
-		{"1 < 1", false},
-		{"1 > 1", false},
-		{"1 == 1", true},
-		{"1 != 1", false},
-		{"1 == 2", false},
-		{"1 != 2", true},
-		{"true == true", true},
-		{"false == false", true},
-		{"true == false", false},
-		{"true != false", true},
-		{"false != true", true},
-		{"(1 < 2) == true", true},
-		{"(1 < 2) == false", false},
-		{"(1 > 2) == true", false},
-		{"(1 > 2) == false", true},
-		{"1<=2", true},
-		{"1>=2", false},
-		{"1<=1", true},
-		{"1>=1", true},
-	}
-
-	for i, tt := range tests {
-		evaluated := testEval(tt.input)
-		testBooleanObject(t, i, evaluated, tt.expected)
-	}
-}
+func TestFunctionObject(t *testing.T) {
+	input := "fn(x) { x + 2; };"
 
-func testBooleanObject(t *testing.T, id int, evaluated object.Object, expected bool) bool {
-	result, ok := evaluated.(*object.Boolean)
+	evaluated := testEval(input)
+	fn, ok := evaluated.(*object.Function)
 	if !ok {
-		t.Errorf("test %d: object is not Boolean. got=%T (%+v)", id, evaluated, evaluated)
-		return false
-	}
-	if result.Value != expected {
-		t.Errorf("test %d: object has wrong value. got=%t, want=%t", id, result.Value, expected)
-		return false
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
 	}
-	return true
-}
 
-func TestBangOperator(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected bool
-	}{
-		{"!true", false},
-		{"!false", true},
-		{"!5", false},
-		{"!!true", true},
-		{"!!false", false},
-		{"!!5", true},
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters. Parameters=%+v", fn.Parameters)
 	}
 
-	for i, tt := range tests {
-		evaluated := testEval(tt.input)
-		testBooleanObject(t, i, evaluated, tt.expected)
+	if fn.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
 	}
-}
 
-func TestIfElseExpressions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{"if (true) { 10 }", 10},
-		{"if (false) { 10 }", nil},
-		{"if (1) { 10 }", 10},
-		{"if (1 < 2) { 10 }", 10},
-		{"if (1 > 2) { 10 }", nil},
-		{"if (1 > 2) { 10 } else { 20 }", 20},
-		{"if (1 < 2) { 10 } else { 20 }", 10},
-	}
+	expectedBody := "(x + 2)"
 
-	for i, tt := range tests {
-		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, i, evaluated, int64(integer))
-		} else {
-			testNullObject(t, evaluated)
-		}
+	if fn.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
 	}
 }
 
-func testNullObject(t *testing.T, evaluated object.Object) bool {
-	if evaluated != NULL {
-		t.Errorf("object is not NULL. got=%T (%+v)", evaluated, evaluated)
-		return false
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+{
+	"one": 10 - 9,
+	two: 1 + 1,
+	"thr" + "ee": 6 / 2,
+	4: 4,
+	true: 5,
+	false: 6
+}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
-	return true
-}
 
-func TestReturnStatements(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"return 10;", 10},
-		{"return 10; 9;", 10},
-		{"return 2 * 5; 9;", 10},
-		{"9; return 2 * 5; 9;", 10},
-		{`
-if (10 > 1) {
-	if (10 > 1) {
-		return 10;
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
 	}
 
-	return 1;
-}
-`, 10},
-		{`
-let f = fn(x) {
-	return x;
-	x + 10;
-};
-
-f(10);
-`, 10},
-		{`
-let f = fn(x) {
-	let result = x + 10;
-	return result;
-	return 10;
-};
-
-f(10);
-`, 20},
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
 	}
 
-	for i, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, i, evaluated, tt.expected)
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, int(expectedValue), pair.Value, expectedValue)
 	}
 }
 
-func TestErrorHandling(t *testing.T) {
+func TestReturnsOutsideOfTailPosition(t *testing.T) {
 	tests := []struct {
 		input    string
 		expected string
 	}{
-		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
-		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
-		{"-true", "unknown operator: -BOOLEAN"},
-		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
-		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
-		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
-		{`
-if (10 > 1) {
-	if (10 > 1) {
-		return true + false;
+		{"let g = fn() { 5 }; let f = fn() { [if (true) { return g() }] }; f()", "[5]"},
+		{"let g = fn() { 5 }; let f = fn() { let r = if (true) { return g() }; [r] }; f()", "[5]"},
+		{`let g = fn() { 5 }; let f = fn() { format("%s", if (true) { return g() }) }; f()`, "5"},
+		{"let g = fn() { 5 }; let r = if (true) { return g() }; [r]", "[5]"},
+		{"let g = fn() { 5 }; let f = fn() { for (x in [1]) { if (x == 1) { return g() } } 0 }; f()", "5"},
 	}
 
-	return 1;
-}
-`, "unknown operator: BOOLEAN + BOOLEAN"},
-		{"foobar", "identifier not found: foobar"},
-		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
-		//{ `{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
-	}
-
-	for i, tt := range tests {
+	for _, tt := range tests {
 		evaluated := testEval(tt.input)
-		errObj, ok := evaluated.(*object.Error)
-		if !ok {
-			t.Errorf("test %d: no error object returned. got=%T (%+v)", i, evaluated, evaluated)
-			continue
-		}
-		if errObj.Err.Error() != tt.expected {
-			t.Errorf("test %d: wrong error message. expected=%q, got=%q", i, tt.expected, errObj.Err.Error())
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: wrong result. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
 		}
 	}
 }
 
-func TestLetStatements(t *testing.T) {
+func TestRecursionDepthLimit(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected int64
+		maxDepth int
+		expected interface{}
 	}{
-		{"let a = 5; a;", 5},
-		{"let a = 5 * 5; a;", 25},
-		{"let a = 5; let b = a; b;", 5},
-		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(4)", 5, 4},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(5)", 5, "maximum recursion depth exceeded: `f` x 5"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(20000)", 0, 20000},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(100)", 5, 0},
+		{"let f = fn() { 1 + fn() { 1 + f() }() }; let g = fn() { 1 + f() }; g()", 4,
+			"maximum recursion depth exceeded: `g` -> `f` -> anonymous function -> `f`"},
+		{"let f = fn(n) { 1 + f(n + 1) }; let g = fn() { 1 + f(0) }; g()", 1000, "maximum recursion depth exceeded: `g` -> `f` x 999"},
+		{"let a = fn(n) { 1 + b(n) }; let b = fn(n) { 1 + a(n) }; a(0)", 12,
+			"maximum recursion depth exceeded: `a` -> `b` -> `a` -> `b` -> `a` -> ... -> `b` -> `a` -> `b` -> `a` -> `b`"},
+		// functions called back by builtins count, even when they return to the builtin in tail position
+		{"let f = fn(x) { map([1], fn(y) { f(x) }) }; f(1)", 20, "maximum recursion depth exceeded: `f` x 20"},
+		{"let f = fn(x) { filter([1], fn(y) { f(x) }) }; f(1)", 20, "maximum recursion depth exceeded: `f` x 20"},
+		{"let f = fn(x) { reduce([1], fn(acc, y) { f(x) }, 0) }; f(1)", 20, "maximum recursion depth exceeded: `f` x 20"},
+		{"let f = fn(x) { map([1], fn(y) { 1 + f(x) }) }; f(1)", 5,
+			"maximum recursion depth exceeded: `f` -> anonymous function -> `f` -> anonymous function -> `f`"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { map([n], fn(y) { f(n - 1) })[0] } }; f(3)", 4, 0},
 	}
 
 	for i, tt := range tests {
-		testIntegerObject(t, i, testEval(tt.input), tt.expected)
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		env := object.NewEnvironment()
+		Configure(env, Options{MaxDepth: tt.maxDepth})
+		evaluated := Eval(program, env)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("test %d: no error object returned. got=%T (%+v)", i, evaluated, evaluated)
+				continue
+			}
+			if errObj.Err.Error() != expected {
+				t.Errorf("test %d: wrong error message. expected=%q, got=%q", i, expected, errObj.Err.Error())
+			}
+		}
 	}
 }
 
-func TestFunctionObject(t *testing.T) {
-	input := "fn(x) { x + 2; };"
-
-	evaluated := testEval(input)
-	fn, ok := evaluated.(*object.Function)
-	if !ok {
-		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
-	}
+func TestRecursionDepthAfterError(t *testing.T) {
+	env := object.NewEnvironment()
+	Configure(env, Options{MaxDepth: 3})
 
-	if len(fn.Parameters) != 1 {
-		t.Fatalf("function has wrong parameters. Parameters=%+v", fn.Parameters)
+	inputs := []string{
+		"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } };",
+		"f(10)",
+		"let g = fn() { 1 + true };",
+		"1 + g()",
 	}
-
-	if fn.Parameters[0].String() != "x" {
-		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	for _, input := range inputs {
+		Eval(parser.New(lexer.New(input)).ParseProgram(), env)
 	}
 
-	expectedBody := "(x + 2)"
-
-	if fn.Body.String() != expectedBody {
-		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
-	}
+	// failed calls must not count towards the depth of later calls
+	testIntegerObject(t, 0, Eval(parser.New(lexer.New("f(2)")).ParseProgram(), env), 2)
 }
 
-func TestFunctionApplication(t *testing.T) {
+func TestEvalContext(t *testing.T) {
 	tests := []struct {
-		input    string
-		expected int64
+		input       string
+		options     Options
+		timeout     time.Duration
+		expectedErr error
+		expected    string
 	}{
-		{"let identity = fn(x) { x; }; identity(5);", 5},
-		{"let identity = fn(x) { return x; }; identity(5);", 5},
-		{"let double = fn(x) { x * 2; }; double(5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
-		{"fn(x) { x; }(5)", 5},
+		{"while (true) { }", Options{}, 10 * time.Millisecond, ErrCanceled, "evaluation canceled: context deadline exceeded"},
+		{"let f = fn() { f() }; f()", Options{}, 10 * time.Millisecond, context.DeadlineExceeded, "evaluation canceled: context deadline exceeded"},
+		{"while (true) { }", Options{MaxSteps: 100}, time.Minute, ErrStepBudgetExceeded, "step budget exceeded: more than 100 steps"},
+		{`let s = "ab"; while (true) { s = s + s; }`, Options{MaxAllocations: 1000}, time.Minute, ErrAllocationBudgetExceeded, "allocation budget exceeded: more than 1000 values"},
+		{"for (x in [1, 2, 3]) { [1, 2, 3, 4, 5, 6, 7, 8, 9, 10] }", Options{MaxAllocations: 20}, time.Minute, ErrAllocationBudgetExceeded, "allocation budget exceeded: more than 20 values"},
+		{"map([1], fn(x) { while (true) { } })", Options{}, 10 * time.Millisecond, ErrCanceled, "evaluation canceled: context deadline exceeded"},
+		{"map(range(100), fn(x) { x * 2 })", Options{MaxSteps: 100}, time.Minute, ErrStepBudgetExceeded, "step budget exceeded: more than 100 steps"},
+		// builtins looping in Go stop too
+		{"len(range(0, 300000000))", Options{}, 50 * time.Millisecond, ErrCanceled, "evaluation canceled: context deadline exceeded"},
+		{`len(repeat("ab", 500000000))`, Options{}, time.Millisecond, ErrCanceled, "evaluation canceled: context deadline exceeded"},
+		{"sort(range(1000))", Options{MaxSteps: 1500}, time.Minute, ErrStepBudgetExceeded, "step budget exceeded: more than 1500 steps"},
+		{"map(range(1000), float)", Options{MaxSteps: 1500}, time.Minute, ErrStepBudgetExceeded, "step budget exceeded: more than 1500 steps"},
+		{"all(range(1, 1000))", Options{MaxSteps: 1500}, time.Minute, ErrStepBudgetExceeded, "step budget exceeded: more than 1500 steps"},
+		// builtins fail before allocating a result exceeding the budget
+		{`repeat("x", 1 << 20)`, Options{MaxAllocations: 1000}, time.Minute, ErrAllocationBudgetExceeded, "allocation budget exceeded: more than 1000 values"},
+		{"range(0, 1 << 40)", Options{MaxAllocations: 1000}, time.Minute, ErrAllocationBudgetExceeded, "allocation budget exceeded: more than 1000 values"},
+		{"range(0, -9223372036854775807 - 1, -1)", Options{MaxAllocations: 1000}, time.Minute, ErrAllocationBudgetExceeded, "allocation budget exceeded: more than 1000 values"},
+		{"let a = range(400); concat(a, a, a)", Options{MaxAllocations: 1000}, time.Minute, ErrAllocationBudgetExceeded, "allocation budget exceeded: more than 1000 values"},
+		{`format("%999999d", 1)`, Options{MaxAllocations: 1000}, time.Minute, ErrAllocationBudgetExceeded, "allocation budget exceeded: more than 1000 values"},
 	}
 
 	for i, tt := range tests {
-		testIntegerObject(t, i, testEval(tt.input), tt.expected)
-	}
-}
-
-func TestClosures(t *testing.T) {
-	input := `
-let newAdder = fn(x) {
-	fn(y) { x + y };
-};
-
-let addTwo = newAdder(2);
-addTwo(2);
-`
-
-	testIntegerObject(t, 0, testEval(input), 4)
-}
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		env := object.NewEnvironment()
+		Configure(env, tt.options)
 
-func TestStringLiteral(t *testing.T) {
-	input := `"Hello World!";`
+		ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+		evaluated := EvalContext(ctx, program, env)
+		cancel()
 
-	evaluated := testEval(input)
-	str, ok := evaluated.(*object.String)
-	if !ok {
-		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("test %d: no error object returned. got=%T (%+v)", i, evaluated, evaluated)
+			continue
+		}
+		if !errors.Is(errObj.Err, tt.expectedErr) {
+			t.Errorf("test %d: error is not %q. got=%q", i, tt.expectedErr, errObj.Err)
+		}
+		if errObj.Err.Error() != tt.expected {
+			t.Errorf("test %d: wrong error message. expected=%q, got=%q", i, tt.expected, errObj.Err.Error())
+		}
 	}
+}
 
-	if str.Value != "Hello World!" {
-		t.Errorf("String has wrong value. got=%q", str.Value)
+func TestBudgetsStartOver(t *testing.T) {
+	evalFuncs := map[string]func(ast.Node, *object.Environment) object.Object{
+		"Eval": Eval,
+		"EvalContext": func(node ast.Node, env *object.Environment) object.Object {
+			return EvalContext(context.Background(), node, env)
+		},
 	}
-}
 
-func TestStringConcatenation(t *testing.T) {
-	input := `"Hello" + " " + "World!";`
+	for name, eval := range evalFuncs {
+		env := object.NewEnvironment()
+		Configure(env, Options{MaxSteps: 50, MaxAllocations: 20})
 
-	evaluated := testEval(input)
-	str, ok := evaluated.(*object.String)
-	if !ok {
-		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
-	}
+		// every line stays within the budgets on its own
+		for i := 0; i < 10; i++ {
+			program := parser.New(lexer.New("let x = [1 + 2 * 3];")).ParseProgram()
+			if evaluated := eval(program, env); isError(evaluated) {
+				t.Fatalf("%s: line %d: unexpected error %s", name, i, evaluated.Inspect())
+			}
+		}
 
-	if str.Value != "Hello World!" {
-		t.Errorf("String has wrong value. got=%q", str.Value)
+		program := parser.New(lexer.New("x[0]")).ParseProgram()
+		testIntegerObject(t, 0, eval(program, env), 7)
 	}
 }
 
-func TestBuiltinFunctions(t *testing.T) {
+func TestBuiltinAllocationsWithinBudget(t *testing.T) {
 	tests := []struct {
-		input    string
-		expected interface{}
+		input          string
+		maxAllocations int
+		expected       string
 	}{
-		{`len("")`, 0},
-		{`len("four")`, 4},
-		{`len("hello world")`, 11},
-		{`len(1)`, "argument to `len` not supported, got INTEGER"},
-		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
-		//{`len([])`, 0},
-		//{`len([1, 2, 3])`, 3},
-		//{`first([1, 2, 3])`, 1},
-		//{`first([])`, nil},
-		//{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
-		//{`last([1, 2, 3])`, 3},
-		//{`last([])`, nil},
-		//{`last(1)`, "argument to `last` must be ARRAY, got INTEGER"},
-		//{`rest([1, 2, 3])`, []int{2, 3}},
-		//{`rest([])`, nil},
-		//{`rest(1)`, "argument to `rest` must be ARRAY, got INTEGER"},
-		//{`push([], 1)`, []int{1}},
-		//{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		// the literals of the arguments count as well
+		{"range(0, 10, 3)", 8, "[0, 3, 6, 9]"},
+		{"range(0, 10, 3)", 7, "ERROR: 1:6: allocation budget exceeded: more than 7 values"},
+		{`repeat("ab", 2)`, 9, "abab"},
+		{`repeat("ab", 2)`, 8, "ERROR: 1:7: allocation budget exceeded: more than 8 values"},
+		{"let a = [1, 2]; concat(a, a)", 10, "[1, 2, 1, 2]"},
+		{"let a = [1, 2]; concat(a, a)", 9, "ERROR: 1:23: allocation budget exceeded: more than 9 values"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		Configure(env, Options{MaxAllocations: tt.maxAllocations})
+
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: wrong result. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
 	}
+}
 
-	for i, tt := range tests {
-		evaluated := testEval(tt.input)
-
-		switch expected := tt.expected.(type) {
-		case int:
-			testIntegerObject(t, i, evaluated, int64(expected))
-		//case []int:
-		//	array, ok := evaluated.(*object.Array)
-		//	if !ok {
-		//		t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
-		//		continue
-		//	}
-		//
-		//	if len(array.Elements) != len(expected) {
-		//		t.Errorf("array has wrong num of elements. got=%d", len(array.Elements))
-		//		continue
-		//	}
-		//
-		//	for i, expected := range expected {
-		//		testIntegerObject(t, i, array.Elements[i], int64(expected))
-		//	}
-		//case nil:
-		//	testNullObject(t, i, evaluated)
-		case string:
-			errObj, ok := evaluated.(*object.Error)
-			if !ok {
-				t.Errorf("test %d: object is not Error. got=%T (%+v)", i, evaluated, evaluated)
-				continue
-			}
-			if errObj.Err.Error() != expected {
-				t.Errorf("test %d: wrong error message. expected=%q, got=%q", i, expected, errObj.Err.Error())
-			}
+func TestRangeLength(t *testing.T) {
+	tests := []struct {
+		start, end, step int64
+		expected         int
+	}{
+		{0, 10, 3, 4},
+		{0, 9, 3, 3},
+		{5, 0, -2, 3},
+		{5, 2, 1, 0},
+		{2, 5, -1, 0},
+		{math.MaxInt64 - 1, math.MaxInt64, 2, 1},
+		{math.MinInt64, math.MaxInt64, 1, math.MaxInt - 1},
+		{math.MaxInt64, math.MinInt64, math.MinInt64, 2},
+	}
+
+	for _, tt := range tests {
+		if actual := rangeLength(tt.start, tt.end, tt.step); actual != tt.expected {
+			t.Errorf("rangeLength(%d, %d, %d) wrong. expected=%d, got=%d", tt.start, tt.end, tt.step, tt.expected, actual)
 		}
 	}
 }
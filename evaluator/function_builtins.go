@@ -0,0 +1,256 @@
+package evaluator
+
+import (
+	"sort"
+	"waixg/interpreter/object"
+)
+
+// The builtins in this file call a function of the program for the elements of an array.
+// An error of the function ends the builtin and is returned as its result.
+
+// isCallable reports whether obj can be called like a function
+func isCallable(obj object.Object) bool {
+	return obj.Type() == object.FunctionObj || obj.Type() == object.BuiltinObj
+}
+
+// arrayAndFunctionArgs returns the elements of the array and the function passed to the builtin name.
+// The function is optional if optional is true, nil is returned for it if it was left out.
+func arrayAndFunctionArgs(name string, args []object.Object, optional bool) ([]object.Object, object.Object, *object.Error) {
+	if len(args) == 1 && optional {
+		elements, err := arrayArg(name, args)
+		return elements, nil, err
+	}
+	if len(args) != 2 {
+		want := "2"
+		if optional {
+			want = "1 or 2"
+		}
+		return nil, nil, newError("wrong number of arguments. got=%d, want=%s", len(args), want)
+	}
+
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, nil, newError("argument to `%s` not supported, got %s", name, args[0].Type())
+	}
+	if !isCallable(args[1]) {
+		return nil, nil, newError("argument to `%s` not supported, got %s", name, args[1].Type())
+	}
+
+	return array.Elements, args[1], nil
+}
+
+// builtinMap returns an array of the results of calling a function with every element of an array
+func builtinMap(ctx object.Context, args ...object.Object) object.Object {
+	elements, fn, err := arrayAndFunctionArgs("map", args, false)
+	if err != nil {
+		return err
+	}
+
+	results := make([]object.Object, len(elements))
+	for i, el := range elements {
+		if err := tick(ctx); err != nil {
+			return err
+		}
+		result := ctx.Call(fn, el)
+		if isError(result) {
+			return result
+		}
+		results[i] = result
+	}
+	return &object.Array{Elements: results}
+}
+
+// builtinFilter returns an array of the elements of an array for which a function returns a truthy value
+func builtinFilter(ctx object.Context, args ...object.Object) object.Object {
+	elements, fn, err := arrayAndFunctionArgs("filter", args, false)
+	if err != nil {
+		return err
+	}
+
+	results := []object.Object{}
+	for _, el := range elements {
+		if err := tick(ctx); err != nil {
+			return err
+		}
+		keep := ctx.Call(fn, el)
+		if isError(keep) {
+			return keep
+		}
+		if isTruthy(keep) {
+			results = append(results, el)
+		}
+	}
+	return &object.Array{Elements: results}
+}
+
+// builtinReduce combines the elements of an array from left to right, by calling a function with the
+// result so far and the next element. It starts with the initial value if one is given, otherwise with
+// the first element.
+func builtinReduce(ctx object.Context, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+	elements, fn, err := arrayAndFunctionArgs("reduce", args[:2], false)
+	if err != nil {
+		return err
+	}
+
+	var acc object.Object
+	if len(args) == 3 {
+		acc = args[2]
+	} else {
+		if len(elements) == 0 {
+			return newError("`reduce` of an empty array without an initial value")
+		}
+		acc, elements = elements[0], elements[1:]
+	}
+
+	for _, el := range elements {
+		if err := tick(ctx); err != nil {
+			return err
+		}
+		acc = ctx.Call(fn, acc, el)
+		if isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+// builtinSort returns an array of the elements of an array in ascending order. The sort is stable.
+// Without a comparator, the elements must be all numbers or all strings. A comparator is called with two
+// elements and returns whether the first one comes before the second one, either as a boolean or as an
+// integer which is negative if it does.
+func builtinSort(ctx object.Context, args ...object.Object) object.Object {
+	elements, fn, err := arrayAndFunctionArgs("sort", args, true)
+	if err != nil {
+		return err
+	}
+
+	sorted := copyElements(elements)
+	var sortErr object.Object
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+		if err := tick(ctx); err != nil {
+			sortErr = err
+			return false
+		}
+
+		var less bool
+		if fn == nil {
+			less, sortErr = compareObjects(sorted[i], sorted[j])
+		} else {
+			less, sortErr = callComparator(ctx, fn, sorted[i], sorted[j])
+		}
+		return less
+	})
+	if sortErr != nil {
+		return sortErr
+	}
+
+	return &object.Array{Elements: sorted}
+}
+
+// compareObjects reports whether a is less than b for sorting without a comparator
+func compareObjects(a, b object.Object) (bool, object.Object) {
+	if isNumber(a) && isNumber(b) {
+		return evalInfixExpression("<", a, b) == TRUE, nil
+	}
+
+	aStr, aOk := a.(*object.String)
+	bStr, bOk := b.(*object.String)
+	if aOk && bOk {
+		return aStr.Value < bStr.Value, nil
+	}
+
+	return false, newError("cannot compare %s and %s in `sort`", a.Type(), b.Type())
+}
+
+// callComparator reports whether a comes before b according to the comparator fn
+func callComparator(ctx object.Context, fn object.Object, a, b object.Object) (bool, object.Object) {
+	result := ctx.Call(fn, a, b)
+	switch result := result.(type) {
+	case *object.Error:
+		return false, result
+	case *object.Boolean:
+		return result.Value, nil
+	case *object.Integer:
+		return result.Value < 0, nil
+	default:
+		return false, newError("comparator of `sort` must return BOOLEAN or INTEGER, got %s", result.Type())
+	}
+}
+
+// builtinAny reports whether a function returns a truthy value for any element of an array.
+// Without a function, the elements themselves are checked. It stops at the first truthy value.
+func builtinAny(ctx object.Context, args ...object.Object) object.Object {
+	return findTruthiness(ctx, "any", args, true)
+}
+
+// builtinAll reports whether a function returns a truthy value for all elements of an array.
+// Without a function, the elements themselves are checked. It stops at the first value that is not truthy.
+func builtinAll(ctx object.Context, args ...object.Object) object.Object {
+	return findTruthiness(ctx, "all", args, false)
+}
+
+// findTruthiness returns whether there is an element for which the function passed in args returns a
+// value whose truthiness is truthy. If there isn't, the result is !truthy.
+func findTruthiness(ctx object.Context, name string, args []object.Object, truthy bool) object.Object {
+	elements, fn, err := arrayAndFunctionArgs(name, args, true)
+	if err != nil {
+		return err
+	}
+
+	for _, el := range elements {
+		if err := tick(ctx); err != nil {
+			return err
+		}
+		value := el
+		if fn != nil {
+			value = ctx.Call(fn, el)
+			if isError(value) {
+				return value
+			}
+		}
+		if isTruthy(value) == truthy {
+			return nativeBoolToBooleanObject(truthy)
+		}
+	}
+	return nativeBoolToBooleanObject(!truthy)
+}
+
+// builtinGroupBy returns a hash from the keys a function returns for the elements of an array to
+// arrays of the elements with that key, in their original order
+func builtinGroupBy(ctx object.Context, args ...object.Object) object.Object {
+	elements, fn, err := arrayAndFunctionArgs("groupBy", args, false)
+	if err != nil {
+		return err
+	}
+
+	groups := object.NewHash()
+	for _, el := range elements {
+		if err := tick(ctx); err != nil {
+			return err
+		}
+		key := ctx.Call(fn, el)
+		if isError(key) {
+			return key
+		}
+		hashable, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		hashKey := hashable.HashKey()
+		group, ok := groups.Pairs[hashKey]
+		if !ok {
+			group = object.HashPair{Key: key, Value: &object.Array{}}
+			groups.Set(hashKey, group)
+		}
+		array := group.Value.(*object.Array)
+		array.Elements = append(array.Elements, el)
+	}
+	return groups
+}
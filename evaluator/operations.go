@@ -0,0 +1,53 @@
+package evaluator
+
+import "waixg/interpreter/object"
+
+// The functions in this file expose the semantics of the language's operators and builtins,
+// so the bytecode virtual machine behaves exactly like the tree-walking evaluator.
+
+// EvalInfix applies a binary operator like + or == to two values.
+func EvalInfix(operator string, left object.Object, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// EvalPrefix applies a unary operator like - or ! to a value.
+func EvalPrefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// EvalIndex looks up an element of an array or hash.
+func EvalIndex(left object.Object, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// EvalSlice takes a slice of an array or string, bounds that are left out are null.
+func EvalSlice(left, start, end, step object.Object) object.Object {
+	return evalSliceExpression(left, start, end, step)
+}
+
+// EvalIndexAssignment stores value as an element of an array or hash.
+func EvalIndexAssignment(left object.Object, index object.Object, value object.Object) object.Object {
+	return evalIndexAssignment(left, index, value)
+}
+
+// IsTruthy reports whether obj counts as true in a condition.
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// LookupBuiltin returns the builtin function with the given name.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// NewError creates an error object like the ones returned by the evaluator.
+func NewError(format string, a ...interface{}) *object.Error {
+	return newError(format, a...)
+}
+
+// NewRecursionError creates the error of a call exceeding the maximum recursion depth.
+// calls are the names of the functions being called, the outermost first.
+func NewRecursionError(calls []string) *object.Error {
+	return newError("maximum recursion depth exceeded: %s", formatCallChain(calls))
+}
@@ -0,0 +1,241 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"waixg/interpreter/ast"
+	"waixg/interpreter/object"
+)
+
+// Errors stopping an evaluation from the outside. The error objects returned by the evaluator wrap
+// them, so they can be told apart from errors of the program with errors.Is.
+var (
+	ErrCanceled                 = errors.New("evaluation canceled")
+	ErrStepBudgetExceeded       = errors.New("step budget exceeded")
+	ErrAllocationBudgetExceeded = errors.New("allocation budget exceeded")
+)
+
+// Options configure the evaluation of programs
+type Options struct {
+	// MaxDepth is the maximum number of nested function calls, 0 means no limit.
+	// Calls in tail position don't count, since they replace their caller. Functions called back by
+	// builtins like map always count, even if they are called in tail position.
+	MaxDepth int
+
+	// MaxSteps is the maximum number of nodes evaluated for a program, 0 means no limit.
+	// Builtins looping in Go, like range, sort or map, count every iteration as a step too.
+	MaxSteps int
+
+	// MaxAllocations is the maximum number of values allocated for a program, 0 means no limit.
+	// Every new object counts once, strings, arrays and hashes additionally count their length.
+	MaxAllocations int
+
+	// Output is where builtins like puts write to, os.Stdout if nil.
+	Output io.Writer
+}
+
+// DefaultOptions are used for environments that were not configured with Configure.
+// The default depth keeps deeply recursing programs well below the stack limit of Go.
+var DefaultOptions = Options{
+	MaxDepth: 10000,
+}
+
+// Configure sets the options for evaluating programs in env and every environment enclosed by it.
+func Configure(env *object.Environment, opts Options) {
+	stateOf(env).options = opts
+}
+
+// EvalContext evaluates node in env like Eval, until ctx is done or a budget of the options
+// configured for env is used up. The budgets start over with every call.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	state := stateOf(env)
+	state.ctx = ctx
+	state.resetBudgets()
+	defer func() { state.ctx = nil }()
+
+	return Eval(node, env)
+}
+
+// evalState is the state of the evaluation of a program, shared by all of its environments
+type evalState struct {
+	options  Options
+	builtins map[string]*object.Builtin // defined with DefineBuiltin
+	calls    []string                   // names of the functions currently being called, the innermost last
+
+	ctx         context.Context // nil unless evaluating with EvalContext
+	steps       int
+	allocations int
+}
+
+// stateOf returns the state of the evaluation in env, creating it with the default options if needed
+func stateOf(env *object.Environment) *evalState {
+	state, ok := env.State().(*evalState)
+	if !ok {
+		state = &evalState{options: DefaultOptions}
+		env.SetState(state)
+	}
+	return state
+}
+
+// Call applies fn to args on behalf of a builtin, the state is the object.Context builtins are called with
+func (s *evalState) Call(fn object.Object, args ...object.Object) object.Object {
+	result := applyFunction(s, fn, args)
+	if result == nil {
+		// e.g. a function ending with a let statement
+		return NULL
+	}
+	return result
+}
+
+// Output returns the writer of builtins like puts, the state is the object.Context builtins are called with
+func (s *evalState) Output() io.Writer {
+	if s.options.Output == nil {
+		return os.Stdout
+	}
+	return s.options.Output
+}
+
+// enterCall records a call of fn at the given level of nesting, returning an error with the current call
+// chain if it exceeds the maximum depth. A call at a level that is already taken replaces the function
+// recorded there, like a call in tail position replaces its caller.
+func (s *evalState) enterCall(level int, fn *object.Function) *object.Error {
+	if level < len(s.calls) {
+		s.calls[level] = functionName(fn)
+		return nil
+	}
+
+	if s.options.MaxDepth > 0 && len(s.calls) >= s.options.MaxDepth {
+		return NewRecursionError(s.calls)
+	}
+
+	s.calls = append(s.calls, functionName(fn))
+	return nil
+}
+
+// leaveCalls forgets the calls from the given level of nesting on
+func (s *evalState) leaveCalls(level int) {
+	s.calls = s.calls[:level]
+}
+
+// resetBudgets starts counting the steps and allocations of an evaluation over
+func (s *evalState) resetBudgets() {
+	s.steps = 0
+	s.allocations = 0
+}
+
+// step counts the evaluation of a node, returning an error if the evaluation has to stop
+func (s *evalState) step() *object.Error {
+	if s.ctx != nil {
+		select {
+		case <-s.ctx.Done():
+			return &object.Error{Err: fmt.Errorf("%w: %w", ErrCanceled, s.ctx.Err())}
+		default:
+		}
+	}
+
+	s.steps++
+	if s.options.MaxSteps > 0 && s.steps > s.options.MaxSteps {
+		return &object.Error{Err: fmt.Errorf("%w: more than %d steps", ErrStepBudgetExceeded, s.options.MaxSteps)}
+	}
+
+	return nil
+}
+
+// allocate counts the allocation of obj
+func (s *evalState) allocate(obj object.Object) *object.Error {
+	return s.charge(allocationCost(obj))
+}
+
+// charge counts the allocation of n values, returning an error if the allocation budget is used up
+func (s *evalState) charge(n int) *object.Error {
+	s.allocations += n
+	if s.options.MaxAllocations > 0 && s.allocations > s.options.MaxAllocations {
+		return s.allocationBudgetExceeded()
+	}
+
+	return nil
+}
+
+// reserve checks that n more values fit into the allocation budget of the evaluation calling a builtin,
+// so the builtin can fail before allocating a result which is too large. Only the result that is returned
+// in the end is charged.
+func reserve(ctx object.Context, n int) *object.Error {
+	s, ok := ctx.(*evalState)
+	if !ok || s.options.MaxAllocations <= 0 {
+		// e.g. builtins called by the virtual machine, which has no budgets
+		return nil
+	}
+
+	if n > s.options.MaxAllocations-s.allocations {
+		return s.allocationBudgetExceeded()
+	}
+	return nil
+}
+
+// tick counts an iteration of a loop inside a builtin as a step of the evaluation calling the builtin,
+// so builtins looping over large values stop once the evaluation is canceled or runs out of steps
+func tick(ctx object.Context) *object.Error {
+	s, ok := ctx.(*evalState)
+	if !ok {
+		// e.g. builtins called by the virtual machine, which can't be canceled
+		return nil
+	}
+
+	return s.step()
+}
+
+func (s *evalState) allocationBudgetExceeded() *object.Error {
+	return &object.Error{Err: fmt.Errorf("%w: more than %d values", ErrAllocationBudgetExceeded, s.options.MaxAllocations)}
+}
+
+// allocationCost returns the number of values obj counts against the allocation budget
+func allocationCost(obj object.Object) int {
+	switch obj := obj.(type) {
+	case nil, *object.Null, *object.Boolean, *object.Error:
+		// booleans and null are singletons, errors end the evaluation anyway
+		return 0
+	case *object.String:
+		return 1 + len(obj.Value)
+	case *object.Array:
+		return 1 + len(obj.Elements)
+	case *object.Hash:
+		return 1 + len(obj.Pairs)
+	default:
+		return 1
+	}
+}
+
+// maxChainLength is the number of entries of a call chain that are shown in full
+const maxChainLength = 10
+
+// formatCallChain joins the names of nested calls, the outermost first.
+// Repeated calls of the same function are shown once with their count, and the middle of a
+// long chain is left out.
+func formatCallChain(calls []string) string {
+	var entries []string
+	for i := 0; i < len(calls); {
+		j := i
+		for j < len(calls) && calls[j] == calls[i] {
+			j++
+		}
+
+		if j-i > 1 {
+			entries = append(entries, fmt.Sprintf("%s x %d", calls[i], j-i))
+		} else {
+			entries = append(entries, calls[i])
+		}
+		i = j
+	}
+
+	if len(entries) > maxChainLength {
+		head := entries[:maxChainLength/2]
+		tail := entries[len(entries)-maxChainLength/2:]
+		entries = append(append(append([]string{}, head...), "..."), tail...)
+	}
+
+	return strings.Join(entries, " -> ")
+}
@@ -0,0 +1,374 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+	"waixg/interpreter/object"
+)
+
+// The string builtins count positions in characters rather than bytes, like len and indexing.
+
+// maxStringLength is the length in bytes of the longest string repeat and format create.
+// It holds even without an allocation budget, so a huge result fails with an error instead of a Go panic.
+const maxStringLength = 1 << 30
+
+// maxFormatWidth is the largest width or precision of a verb in format, fmt.Sprintf ignores larger ones
+const maxFormatWidth = 1000000
+
+// checkArgs returns an error unless args are exactly of the given types
+func checkArgs(name string, args []object.Object, types ...object.ObjectType) *object.Error {
+	if len(args) != len(types) {
+		return newError("wrong number of arguments. got=%d, want=%d", len(args), len(types))
+	}
+
+	for i, typ := range types {
+		if args[i].Type() != typ {
+			return newError("argument to `%s` not supported, got %s", name, args[i].Type())
+		}
+	}
+
+	return nil
+}
+
+// stringFunction returns a builtin applying fn to a single string argument
+func stringFunction(name string, fn func(string) string) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if err := checkArgs(name, args, object.StringObj); err != nil {
+				return err
+			}
+
+			return &object.String{Value: fn(args[0].(*object.String).Value)}
+		},
+	}
+}
+
+// stringPredicate returns a builtin applying fn to two string arguments
+func stringPredicate(name string, fn func(s, sub string) bool) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if err := checkArgs(name, args, object.StringObj, object.StringObj); err != nil {
+				return err
+			}
+
+			return nativeBoolToBooleanObject(fn(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	}
+}
+
+// builtinSplit splits a string around every occurrence of a separator, or into its characters if the
+// separator is empty
+func builtinSplit(ctx object.Context, args ...object.Object) object.Object {
+	if err := checkArgs("split", args, object.StringObj, object.StringObj); err != nil {
+		return err
+	}
+
+	parts := strings.Split(args[0].(*object.String).Value, args[1].(*object.String).Value)
+	elements := make([]object.Object, len(parts))
+	for i, part := range parts {
+		elements[i] = &object.String{Value: part}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// builtinJoin joins the elements of an array with a separator.
+// Elements that are not strings are joined the way they are inspected, like in interpolated strings.
+func builtinJoin(ctx object.Context, args ...object.Object) object.Object {
+	if err := checkArgs("join", args, object.ArrayObj, object.StringObj); err != nil {
+		return err
+	}
+
+	elements := args[0].(*object.Array).Elements
+	parts := make([]string, len(elements))
+	for i, el := range elements {
+		parts[i] = el.Inspect()
+	}
+	return &object.String{Value: strings.Join(parts, args[1].(*object.String).Value)}
+}
+
+// builtinReplace replaces every occurrence of a substring with a replacement
+func builtinReplace(ctx object.Context, args ...object.Object) object.Object {
+	if err := checkArgs("replace", args, object.StringObj, object.StringObj, object.StringObj); err != nil {
+		return err
+	}
+
+	str, old, replacement := args[0].(*object.String).Value, args[1].(*object.String).Value, args[2].(*object.String).Value
+	return &object.String{Value: strings.ReplaceAll(str, old, replacement)}
+}
+
+// builtinContains reports whether a string contains a substring or an array contains a value
+func builtinContains(ctx object.Context, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	if array, ok := args[0].(*object.Array); ok {
+		return nativeBoolToBooleanObject(arrayIndexOf(array, args[1]) >= 0)
+	}
+	if err := checkArgs("contains", args, object.StringObj, object.StringObj); err != nil {
+		return err
+	}
+
+	return nativeBoolToBooleanObject(strings.Contains(args[0].(*object.String).Value, args[1].(*object.String).Value))
+}
+
+// builtinIndexOf returns the position of the first occurrence of a substring in a string or of a value
+// in an array, or -1 if there is none
+func builtinIndexOf(ctx object.Context, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	if array, ok := args[0].(*object.Array); ok {
+		return &object.Integer{Value: int64(arrayIndexOf(array, args[1]))}
+	}
+	if err := checkArgs("indexOf", args, object.StringObj, object.StringObj); err != nil {
+		return err
+	}
+
+	str := args[0].(*object.String).Value
+	i := strings.Index(str, args[1].(*object.String).Value)
+	if i < 0 {
+		return &object.Integer{Value: -1}
+	}
+	return &object.Integer{Value: int64(utf8.RuneCountInString(str[:i]))}
+}
+
+// builtinSubstr returns the characters of a string from start up to the end or, if given, length of them.
+// Like indexing, a negative start counts from the end. The substring is cut off at the ends of the string.
+func builtinSubstr(ctx object.Context, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+	types := []object.ObjectType{object.StringObj, object.IntegerObj, object.IntegerObj}
+	if err := checkArgs("substr", args, types[:len(args)]...); err != nil {
+		return err
+	}
+
+	chars := []rune(args[0].(*object.String).Value)
+	length := int64(len(chars))
+
+	start := args[1].(*object.Integer).Value
+	if start < 0 {
+		start += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > length {
+		start = length
+	}
+
+	end := length
+	if len(args) == 3 {
+		n := args[2].(*object.Integer).Value
+		if n < 0 {
+			return newError("negative length in `substr`: %d", n)
+		}
+		if n < end-start {
+			end = start + n
+		}
+	}
+
+	return &object.String{Value: string(chars[start:end])}
+}
+
+// builtinRepeat returns a string repeated count times
+func builtinRepeat(ctx object.Context, args ...object.Object) object.Object {
+	if err := checkArgs("repeat", args, object.StringObj, object.IntegerObj); err != nil {
+		return err
+	}
+
+	str := args[0].(*object.String).Value
+	count := args[1].(*object.Integer).Value
+	if count < 0 {
+		return newError("negative count in `repeat`: %d", count)
+	}
+	if len(str) > 0 && count > int64(maxStringLength/len(str)) {
+		return newError("count in `repeat` too large: %d", count)
+	}
+	if err := reserve(ctx, 1+len(str)*int(count)); err != nil {
+		return err
+	}
+	if len(str) == 0 {
+		return &object.String{Value: ""}
+	}
+
+	// repeat in chunks, so a long repetition stops once the evaluation is canceled
+	perChunk := repeatChunkSize/len(str) + 1
+	chunk := strings.Repeat(str, perChunk)
+	var out strings.Builder
+	out.Grow(len(str) * int(count))
+	for n := int(count); n > 0; n -= perChunk {
+		if err := tick(ctx); err != nil {
+			return err
+		}
+		if n < perChunk {
+			out.WriteString(chunk[:n*len(str)])
+		} else {
+			out.WriteString(chunk)
+		}
+	}
+	return &object.String{Value: out.String()}
+}
+
+// repeatChunkSize is the number of bytes repeat appends between checking whether to stop
+const repeatChunkSize = 1 << 16
+
+// formatFunction returns a builtin formatting its arguments according to a format string, like fmt.Sprintf.
+// Every verb takes exactly one argument:
+//   - %d, %b, %o and %c take integers, %x and %X integers or strings
+//   - %e, %E, %f, %F, %g and %G take floats or integers
+//   - %t takes booleans
+//   - %s, %q and %v take any value, formatting values other than integers, floats, strings and booleans
+//     as the string they are inspected as
+func formatFunction(name string) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got=0, want at least 1")
+			}
+			format, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `%s` not supported, got %s", name, args[0].Type())
+			}
+
+			result, err := formatString(ctx, name, format.Value, args[1:])
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: result}
+		},
+	}
+}
+
+// formatVerbTypes are the types of the arguments each verb takes, nil for any type
+var formatVerbTypes = map[byte][]object.ObjectType{
+	'd': {object.IntegerObj},
+	'b': {object.IntegerObj},
+	'o': {object.IntegerObj},
+	'c': {object.IntegerObj},
+	'x': {object.IntegerObj, object.StringObj},
+	'X': {object.IntegerObj, object.StringObj},
+	'e': {object.FloatObj, object.IntegerObj},
+	'E': {object.FloatObj, object.IntegerObj},
+	'f': {object.FloatObj, object.IntegerObj},
+	'F': {object.FloatObj, object.IntegerObj},
+	'g': {object.FloatObj, object.IntegerObj},
+	'G': {object.FloatObj, object.IntegerObj},
+	't': {object.BooleanObj},
+	's': nil,
+	'q': nil,
+	'v': nil,
+}
+
+// formatString formats args according to format for the builtin name. Unlike fmt.Sprintf, unknown verbs,
+// arguments not fitting their verb and a wrong number of arguments are errors, and widths and precisions
+// are limited, so the result fits into the allocation budget and maxStringLength.
+func formatString(ctx object.Context, name string, format string, args []object.Object) (string, *object.Error) {
+	var out strings.Builder
+	verbs := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+
+		// a verb is %[flags][width][.precision]verb
+		j := i + 1
+		for j < len(format) && strings.IndexByte("+-# 0", format[j]) >= 0 {
+			j++
+		}
+		width, j := readFormatNumber(format, j)
+		precision := 0
+		if j < len(format) && format[j] == '.' {
+			precision, j = readFormatNumber(format, j+1)
+		}
+		if j == len(format) {
+			return "", newError("incomplete verb in `%s`: %s", name, format[i:])
+		}
+		spec, verb := format[i:j+1], format[j]
+		i = j
+
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		types, ok := formatVerbTypes[verb]
+		if !ok {
+			return "", newError("unknown verb in `%s`: %s", name, spec)
+		}
+		verbs++
+		if verbs > len(args) {
+			// keep counting the verbs for the error
+			continue
+		}
+		arg := args[verbs-1]
+
+		// the result grows by the width and precision, if not by the length of the argument
+		if width > maxFormatWidth || precision > maxFormatWidth || out.Len()+width+precision > maxStringLength {
+			return "", newError("width or precision too large in `%s`: %s", name, spec)
+		}
+		if err := reserve(ctx, 1+out.Len()+width+precision); err != nil {
+			return "", err
+		}
+
+		value, ok := formatValue(arg, types)
+		if !ok {
+			return "", newError("argument for %s in `%s` not supported, got %s", spec, name, arg.Type())
+		}
+		out.WriteString(fmt.Sprintf(spec, value))
+	}
+
+	if verbs != len(args) {
+		return "", newError("wrong number of arguments to `%s`. got=%d, want=%d", name, 1+len(args), 1+verbs)
+	}
+	return out.String(), nil
+}
+
+// readFormatNumber reads the digits of a width or precision starting at i, returning their value and
+// the position after them. Values above maxFormatWidth are returned as maxFormatWidth+1.
+func readFormatNumber(format string, i int) (int, int) {
+	n := 0
+	for ; i < len(format) && format[i] >= '0' && format[i] <= '9'; i++ {
+		if n <= maxFormatWidth {
+			n = n*10 + int(format[i]-'0')
+		}
+	}
+	if n > maxFormatWidth {
+		n = maxFormatWidth + 1
+	}
+	return n, i
+}
+
+// formatValue returns the Go value arg is formatted as, and false if arg is none of types.
+// nil types accept any argument.
+func formatValue(arg object.Object, types []object.ObjectType) (interface{}, bool) {
+	if types != nil {
+		ok := false
+		for _, typ := range types {
+			ok = ok || arg.Type() == typ
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+
+	switch arg := arg.(type) {
+	case *object.Integer:
+		if types != nil && types[0] == object.FloatObj {
+			return float64(arg.Value), true
+		}
+		return arg.Value, true
+	case *object.Float:
+		return arg.Value, true
+	case *object.String:
+		return arg.Value, true
+	case *object.Boolean:
+		return arg.Value, true
+	default:
+		return arg.Inspect(), true
+	}
+}
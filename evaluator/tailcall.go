@@ -0,0 +1,163 @@
+package evaluator
+
+import (
+	"waixg/interpreter/ast"
+	"waixg/interpreter/object"
+)
+
+// tailCall is a call in tail position that has not been applied yet.
+// Instead of recursing into the called function, the body of the caller returns a tailCall and
+// applyFunction continues with it, so tail-recursive functions run in constant Go stack.
+// A tailCall never leaves the evaluator.
+type tailCall struct {
+	fn   object.Object
+	args []object.Object
+	node *ast.CallExpression
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// evalTail evaluates node, whose value becomes the result of the function being called.
+// A call whose value would be returned as is, is not applied but returned as a tailCall.
+func evalTail(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		return evalTailBlockStatement(node, env)
+
+	case *ast.ExpressionStatement:
+		return evalTail(node.Expression, env)
+
+	case *ast.IfExpression:
+		condition := Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return evalTail(node.Consequence, env)
+		} else if node.Alternative != nil {
+			return evalTail(node.Alternative, env)
+		}
+		return NULL
+
+	case *ast.CallExpression:
+		return evalTailCall(node, env)
+
+	case *ast.ReturnStatement:
+		return evalTailReturn(node, env)
+
+	case *ast.WhileStatement, *ast.ForStatement:
+		// the value of a loop doesn't come from its body, but its returns are in tail position
+		return evalReturns(node, env)
+
+	default:
+		return Eval(node, env)
+	}
+}
+
+// evalFunc evaluates a node in an environment, like Eval or evalReturns
+type evalFunc func(node ast.Node, env *object.Environment) object.Object
+
+// evalReturns evaluates a statement of a function body whose value is discarded, unless it returns from
+// the function. The return statements it reaches without passing through an expression are in tail position.
+func evalReturns(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		var result object.Object
+		for _, statement := range node.Statements {
+			result = evalReturns(statement, env)
+			if isSignal(result) {
+				return result
+			}
+		}
+		return result
+
+	case *ast.ExpressionStatement:
+		ie, ok := node.Expression.(*ast.IfExpression)
+		if !ok {
+			return Eval(node, env)
+		}
+
+		condition := Eval(ie.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return evalReturns(ie.Consequence, env)
+		} else if ie.Alternative != nil {
+			return evalReturns(ie.Alternative, env)
+		}
+		return NULL
+
+	case *ast.ReturnStatement:
+		return evalTailReturn(node, env)
+
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env, evalReturns)
+
+	case *ast.ForStatement:
+		return locateError(evalForStatement(node, env, evalReturns), node)
+
+	default:
+		return Eval(node, env)
+	}
+}
+
+// evalTailBlockStatement evaluates a block like evalBlockStatement, with its last statement in tail position
+func evalTailBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	if len(block.Statements) == 0 {
+		return NULL
+	}
+
+	last := len(block.Statements) - 1
+	for _, statement := range block.Statements[:last] {
+		result := evalReturns(statement, env)
+		if isSignal(result) {
+			return result
+		}
+	}
+
+	if result := evalTail(block.Statements[last], env); result != nil {
+		return result
+	}
+	// the last statement has no value, e.g. a let statement
+	return NULL
+}
+
+// isSignal reports whether result ends the evaluation of a block: a return value, an error or a loop signal
+func isSignal(result object.Object) bool {
+	if result == nil {
+		return false
+	}
+
+	rt := result.Type()
+	return rt == object.ReturnValueObj || rt == object.ErrorObj || rt == object.BreakObj || rt == object.ContinueObj
+}
+
+// evalTailReturn evaluates a return statement in tail position, a returned call is left to applyFunction
+func evalTailReturn(node *ast.ReturnStatement, env *object.Environment) object.Object {
+	var val object.Object
+	if call, ok := node.ReturnValue.(*ast.CallExpression); ok {
+		val = evalTailCall(call, env)
+	} else {
+		val = Eval(node.ReturnValue, env)
+	}
+	if isError(val) {
+		return val
+	}
+	return &object.ReturnValue{Value: val}
+}
+
+// evalTailCall evaluates the function and arguments of a call, leaving the call itself to applyFunction
+func evalTailCall(node *ast.CallExpression, env *object.Environment) object.Object {
+	function := Eval(node.Function, env)
+	if isError(function) {
+		return function
+	}
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	return &tailCall{fn: function, args: args, node: node}
+}
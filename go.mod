@@ -1,3 +1,10 @@
 module waixg
 
 go 1.20
+
+require github.com/peterh/liner v1.2.2
+
+require (
+	github.com/mattn/go-runewidth v0.0.3 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+)
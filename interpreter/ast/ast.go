@@ -9,6 +9,7 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of the node's token in the source
 }
 
 type Statement interface {
@@ -32,6 +33,12 @@ func (p *Program) TokenLiteral() string {
 		return ""
 	}
 }
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -50,6 +57,7 @@ type LetStatement struct {
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -73,6 +81,7 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
 func (i *Identifier) String() string       { return i.Value }
 
 type ReturnStatement struct {
@@ -82,6 +91,7 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -103,6 +113,7 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -117,8 +128,19 @@ type IntegerLiteral struct {
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 type PrefixExpression struct {
 	Token    token.Token // the prefix token, e.g. !
 	Operator string
@@ -127,6 +149,7 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -147,6 +170,7 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -166,6 +190,7 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 type IfExpression struct {
@@ -177,6 +202,7 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -199,6 +225,7 @@ type BlockStatement struct {
 
 func (bs *BlockStatement) expressionNode()      {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -211,23 +238,22 @@ func (bs *BlockStatement) String() string {
 
 type FunctionLiteral struct {
 	Token      token.Token // the 'fn' token
+	Name       string      // name of the variable the function is bound to by `let`, if any
 	Parameters []*Identifier
+	Defaults   map[string]Expression // default values of optional parameters, by parameter name
+	Rest       *Identifier           // trailing `...rest` parameter collecting extra arguments, if any
 	Body       *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
-	var params []string
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(FormatParameters(fl.Parameters, fl.Defaults, fl.Rest))
 	out.WriteString(") {")
 	out.WriteString(fl.Body.String())
 	out.WriteString("}")
@@ -235,6 +261,24 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// FormatParameters renders a parameter list like `a, b = 2, ...rest`
+func FormatParameters(parameters []*Identifier, defaults map[string]Expression, rest *Identifier) string {
+	var params []string
+	for _, p := range parameters {
+		if def, ok := defaults[p.Value]; ok {
+			params = append(params, p.String()+" = "+def.String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+
+	if rest != nil {
+		params = append(params, "..."+rest.String())
+	}
+
+	return strings.Join(params, ", ")
+}
+
 type CallExpression struct {
 	Token     token.Token // the '(' token
 	Function  Expression  // Identifier or FunctionLiteral
@@ -243,6 +287,7 @@ type CallExpression struct {
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Token.Pos }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -270,10 +315,45 @@ func (sl *StringLiteral) TokenLiteral() string {
 	return sl.Token.Literal
 }
 
+func (sl *StringLiteral) Pos() token.Position {
+	return sl.Token.Pos
+}
+
 func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
 
+// InterpolatedString is a string with embedded expressions like "sum is ${a + b}".
+// Its parts are the string literals and the expressions in the order they appear in the string.
+type InterpolatedString struct {
+	Token token.Token // the STRING_HEAD token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode() {}
+
+func (is *InterpolatedString) TokenLiteral() string {
+	return is.Token.Literal
+}
+
+func (is *InterpolatedString) Pos() token.Position {
+	return is.Token.Pos
+}
+
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for _, part := range is.Parts {
+		if str, ok := part.(*StringLiteral); ok {
+			out.WriteString(str.Value)
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+
+	return out.String()
+}
+
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
@@ -281,6 +361,7 @@ type ArrayLiteral struct {
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -295,3 +376,166 @@ func (al *ArrayLiteral) String() string {
 
 	return out.String()
 }
+
+type IndexExpression struct {
+	Token token.Token // the '[' token
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// SliceExpression takes a part of a string or array like xs[start:end:step].
+// Start, End and Step are nil if they are left out, as in xs[:-1] or s[::2].
+type SliceExpression struct {
+	Token token.Token // the '[' token
+	Left  Expression
+	Start Expression
+	End   Expression
+	Step  Expression
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+type HashLiteral struct {
+	Token token.Token // the '{' token
+	Pairs map[Expression]Expression
+	Keys  []Expression // the keys of Pairs in source order
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	var pairs []string
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+": "+hl.Pairs[key].String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+type WhileStatement struct {
+	Token     token.Token // the 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+type ForStatement struct {
+	Token    token.Token // the 'for' token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for(")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+type BreakStatement struct {
+	Token token.Token // the 'break' token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) String() string       { return bs.TokenLiteral() + ";" }
+
+type ContinueStatement struct {
+	Token token.Token // the 'continue' token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) String() string       { return cs.TokenLiteral() + ";" }
+
+type AssignExpression struct {
+	Token    token.Token // the assignment operator token, e.g. = or +=
+	Target   Expression  // Identifier or IndexExpression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position  { return ae.Token.Pos }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
@@ -6,26 +6,83 @@ import (
 )
 
 type PeekTypeMismatch struct {
+	Pos      token.Position
 	Expected token.TokenType
 	Actual   token.TokenType
 }
 
 func (e *PeekTypeMismatch) Error() string {
-	return fmt.Sprintf("[PeekTypeMismatch] Expected next token to be %s, got %s instead", e.Expected, e.Actual)
+	return fmt.Sprintf("%s: [PeekTypeMismatch] Expected next token to be %s, got %s instead", e.Pos, e.Expected, e.Actual)
 }
 
 type NoPrefixParseFnError struct {
+	Pos       token.Position
 	TokenType token.TokenType
 }
 
 func (e *NoPrefixParseFnError) Error() string {
-	return fmt.Sprintf("[NoPrefixParseFnError] No prefix parse function for %s found", e.TokenType)
+	return fmt.Sprintf("%s: [NoPrefixParseFnError] No prefix parse function for %s found", e.Pos, e.TokenType)
 }
 
 type InvalidIntegerLiteral struct {
+	Pos     token.Position
 	Literal string
 }
 
 func (e *InvalidIntegerLiteral) Error() string {
-	return fmt.Sprintf("[InvalidIntegerLiteral] Could not parse %q as integer", e.Literal)
+	return fmt.Sprintf("%s: [InvalidIntegerLiteral] Could not parse %q as integer", e.Pos, e.Literal)
+}
+
+type InvalidFloatLiteral struct {
+	Pos     token.Position
+	Literal string
+}
+
+func (e *InvalidFloatLiteral) Error() string {
+	return fmt.Sprintf("%s: [InvalidFloatLiteral] Could not parse %q as float", e.Pos, e.Literal)
+}
+
+type InvalidAssignmentTarget struct {
+	Pos    token.Position
+	Target string
+}
+
+func (e *InvalidAssignmentTarget) Error() string {
+	return fmt.Sprintf("%s: [InvalidAssignmentTarget] Cannot assign to %s", e.Pos, e.Target)
+}
+
+type InvalidParameter struct {
+	Pos    token.Position
+	Name   string
+	Reason string
+}
+
+func (e *InvalidParameter) Error() string {
+	return fmt.Sprintf("%s: [InvalidParameter] Parameter %s %s", e.Pos, e.Name, e.Reason)
+}
+
+type UnterminatedComment struct {
+	Pos token.Position
+}
+
+func (e *UnterminatedComment) Error() string {
+	return fmt.Sprintf("%s: [UnterminatedComment] Block comment is not terminated", e.Pos)
+}
+
+type UnterminatedString struct {
+	Pos token.Position
+	Raw bool // a `raw` string, which may span multiple lines
+}
+
+func (e *UnterminatedString) Error() string {
+	return fmt.Sprintf("%s: [UnterminatedString] String literal is not terminated", e.Pos)
+}
+
+type InvalidEscape struct {
+	Pos      token.Position
+	Sequence string
+}
+
+func (e *InvalidEscape) Error() string {
+	return fmt.Sprintf("%s: [InvalidEscape] Invalid escape sequence %s in string literal", e.Pos, e.Sequence)
 }
@@ -1,36 +1,77 @@
 package lexer
 
-import "waixg/interpreter/token"
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"waixg/interpreter/errors"
+	"waixg/interpreter/token"
+)
 
 type Lexer struct {
 	input        string
-	position     int  // current position in input (points to current char)
-	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	filename     string // name of the source file, used in token positions
+	position     int    // current position in input (points to current char)
+	readPosition int    // current reading position in input (after current char)
+	ch           rune   // current char under examination
+	line         int    // line of the current char
+	column       int    // column of the current char, counted in chars rather than bytes
+	errors       []error
+
+	// the interpolated strings whose ${ is currently open, the innermost last
+	interpolations []interpolation
+}
+
+// interpolation is an expression embedded into a string with ${...}
+type interpolation struct {
+	pos   token.Position // position of the string
+	depth int            // number of braces opened inside of the expression
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// NewFile creates a lexer whose token positions refer to the given filename.
+func NewFile(filename string, input string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1}
 	l.readChar()
 	return l
 }
 
 // readChar() reads the next character in the input string and advances the position of the lexer.
+// Characters are decoded as UTF-8, an invalid byte is read as utf8.RuneError.
 func (l *Lexer) readChar() {
+	// a newline moves the following char to the start of the next line
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
+
+	size := 0
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, size = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += size
+}
+
+// Errors returns the errors found in the input so far, like unterminated comments or strings
+func (l *Lexer) Errors() []error {
+	return l.errors
 }
 
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
-	l.skipWhitespace()
+	comments := l.skipWhitespace()
+
+	pos := l.pos()
 
 	switch l.ch {
 	// Operators
@@ -45,9 +86,19 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		// Check for 2 character operator '+='
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		// Check for 2 character operator '-='
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.MINUS_ASSIGN)
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '!':
 		// Check for 2 character operator '!='
 		if l.peekChar() == '=' {
@@ -59,53 +110,113 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		// Check for 2 character operator '/='
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.SLASH_ASSIGN)
+		} else {
+			tok = newToken(token.SLASH, l.ch)
+		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		// Check for 2 character operator '*='
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.ASTERISK_ASSIGN)
+		} else {
+			tok = newToken(token.ASTERISK, l.ch)
+		}
 	case '<':
-		// Check for 2 character operator '<='
+		// Check for 2 character operators '<=' and '<<'
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.LTEQ, Literal: literal}
+		} else if l.peekChar() == '<' {
+			tok = l.readTwoCharToken(token.SHIFT_LEFT)
 		} else {
 			tok = newToken(token.LT, l.ch)
 		}
 	case '>':
-		// Check for 2 character operator '>='
+		// Check for 2 character operators '>=' and '>>'
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.GTEQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			tok = l.readTwoCharToken(token.SHIFT_RIGHT)
 		} else {
 			tok = newToken(token.GT, l.ch)
 		}
 	case '^':
 		tok = newToken(token.HAT, l.ch)
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
+	case '~':
+		tok = newToken(token.TILDE, l.ch)
+	case '&':
+		// Check for 2 character operator '&&'
+		if l.peekChar() == '&' {
+			tok = l.readTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.AMPERSAND, l.ch)
+		}
+	case '|':
+		// Check for 2 character operator '||'
+		if l.peekChar() == '|' {
+			tok = l.readTwoCharToken(token.OR)
+		} else {
+			tok = newToken(token.PIPE, l.ch)
+		}
 	// Delimiters
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
+	case '.':
+		// Check for 3 character operator '...'
+		if l.peekChar() == '.' && l.peekCharAt(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1].depth++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+		if n := len(l.interpolations); n > 0 && l.interpolations[n-1].depth == 0 {
+			// the end of an embedded expression, the string continues
+			tok = l.readStringPart(token.STRING_MIDDLE, token.STRING_TAIL, l.interpolations[n-1].pos)
+		} else {
+			if n > 0 {
+				l.interpolations[n-1].depth--
+			}
+			tok = newToken(token.RBRACE, l.ch)
+		}
 	case '"':
+		tok = l.readStringPart(token.STRING_HEAD, token.STRING, pos)
+	case '`':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = l.readRawString(pos)
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
 		tok = newToken(token.RBRACKET, l.ch)
 	// EOF
 	case 0:
+		if len(l.interpolations) > 0 {
+			l.errors = append(l.errors, &errors.UnterminatedString{Pos: l.interpolations[0].pos})
+			l.interpolations = nil
+		}
 		tok.Literal = ""
 		tok.Type = token.EOF
 	// Fallthrough
@@ -113,21 +224,38 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = pos
+			tok.Comments = comments
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Pos = pos
+			tok.Comments = comments
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 
+	tok.Pos = pos
+	tok.Comments = comments
 	l.readChar()
 	return tok
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+// pos returns the position of the current char.
+func (l *Lexer) pos() token.Position {
+	return token.Position{Filename: l.filename, Line: l.line, Column: l.column}
+}
+
+// readTwoCharToken consumes the current and the next char as a single token of the given type.
+func (l *Lexer) readTwoCharToken(tokenType token.TokenType) token.Token {
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: tokenType, Literal: string(ch) + string(l.ch)}
+}
+
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
@@ -139,45 +267,245 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber() reads an integer or a float literal.
+// A float has a fractional part (3.14), an exponent (1e-9) or both (2.5E+3).
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
+
+	l.readDigits()
+
+	// a '.' only belongs to the number if a digit follows it
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		// the exponent is only consumed if it is well-formed, otherwise the 'e' starts the next token
+		offset := 1
+		if sign := l.peekCharAt(offset); sign == '+' || sign == '-' {
+			offset++
+		}
+
+		if isDigit(l.peekCharAt(offset)) {
+			tokenType = token.FLOAT
+			for i := 0; i < offset; i++ {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
+}
+
+// skipWhitespace skips whitespace and comments, returning the comments it skipped.
+// Comments are either line comments from // to the end of the line or block comments
+// between /* and */, which don't nest.
+func (l *Lexer) skipWhitespace() []string {
+	var comments []string
+
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			comments = append(comments, l.readLineComment())
+		case l.ch == '/' && l.peekChar() == '*':
+			comments = append(comments, l.readBlockComment())
+		default:
+			return comments
+		}
+	}
+}
+
+// readLineComment reads a comment up to, but not including, the end of the line
+func (l *Lexer) readLineComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+// readBlockComment reads a comment up to and including the closing */.
+// A comment missing the closing */ runs until the end of the input and is reported as an error.
+func (l *Lexer) readBlockComment() string {
+	position := l.position
+	pos := l.pos()
+
+	// skip the opening /*, so it can't be mistaken for the end in /*/
+	l.readChar()
+	l.readChar()
+
+	for !(l.ch == '*' && l.peekChar() == '/') {
+		if l.ch == 0 {
+			l.errors = append(l.errors, &errors.UnterminatedComment{Pos: pos})
+			return l.input[position:l.position]
+		}
 		l.readChar()
 	}
+
+	l.readChar()
+	l.readChar()
+	return l.input[position:l.position]
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// isLetter reports whether ch can be part of an identifier, which are made of unicode letters and _
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
-		return 0
-	} else {
-		return l.input[l.readPosition]
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func (l *Lexer) peekChar() rune {
+	return l.peekCharAt(1)
+}
+
+// peekCharAt returns the char offset positions after the current one without advancing the lexer.
+func (l *Lexer) peekCharAt(offset int) rune {
+	position := l.readPosition
+	for {
+		if position >= len(l.input) {
+			return 0
+		}
+		ch, size := utf8.DecodeRuneInString(l.input[position:])
+		offset--
+		if offset == 0 {
+			return ch
+		}
+		position += size
+	}
+}
+
+// readStringPart reads a string up to its end or the next ${, returning a token of type interpolated
+// if it stopped at a ${ and of type end otherwise. pos is the position of the string.
+func (l *Lexer) readStringPart(interpolated, end token.TokenType, pos token.Position) token.Token {
+	value, open := l.readString(pos)
+	if !open {
+		if end != token.STRING {
+			// the innermost interpolated string ends
+			l.interpolations = l.interpolations[:len(l.interpolations)-1]
+		}
+		return token.Token{Type: end, Literal: value}
+	}
+
+	if interpolated == token.STRING_HEAD {
+		l.interpolations = append(l.interpolations, interpolation{pos: pos})
 	}
+	return token.Token{Type: interpolated, Literal: value}
 }
 
 // readString() reads a string literal from the input string, consumes the leading and trailing "
-// and advances the position of the lexer.
-func (l *Lexer) readString() string {
-	// Skip the opening quote
+// and advances the position of the lexer. It returns the value of the string with its escape
+// sequences replaced. A string missing the closing " ends at the end of the line and is reported
+// as an error.
+// Reading stops at a ${, which is consumed, and open reports whether the string continues after
+// the embedded expression.
+func (l *Lexer) readString(pos token.Position) (value string, open bool) {
+	var out strings.Builder
+	for {
+		l.readChar()
+		switch l.ch {
+		case '"':
+			return out.String(), false
+		case '\n', 0:
+			l.errors = append(l.errors, &errors.UnterminatedString{Pos: pos})
+			return out.String(), false
+		case '$':
+			if l.peekChar() != '{' {
+				out.WriteRune(l.ch)
+				continue
+			}
+			l.readChar()
+			return out.String(), true
+		case '\\':
+			l.readEscape(&out)
+		default:
+			// copy the bytes of the input, so invalid UTF-8 is kept as it is
+			out.WriteString(l.input[l.position:l.readPosition])
+		}
+	}
+}
+
+// readEscape reads the escape sequence starting at the current \ and writes the char it stands for
+// to out. The supported sequences are \n, \t, \r, \\, \", \$ and \u{...} with the hex code point of
+// a unicode char.
+func (l *Lexer) readEscape(out *strings.Builder) {
+	position := l.position
+	pos := l.pos()
+
+	// a \ at the end of the line leaves the string unterminated
+	if l.peekChar() == '\n' || l.peekChar() == 0 {
+		return
+	}
+	l.readChar()
+
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '\\', '"', '$':
+		out.WriteRune(l.ch)
+	case 'u':
+		if l.peekChar() != '{' {
+			l.errors = append(l.errors, &errors.InvalidEscape{Pos: pos, Sequence: l.input[position:l.readPosition]})
+			return
+		}
+		l.readChar()
+
+		digits := l.readPosition
+		for isHexDigit(l.peekChar()) {
+			l.readChar()
+		}
+		hex := l.input[digits:l.readPosition]
+
+		if l.peekChar() != '}' {
+			l.errors = append(l.errors, &errors.InvalidEscape{Pos: pos, Sequence: l.input[position:l.readPosition]})
+			return
+		}
+		l.readChar()
+
+		code, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) > 6 || !utf8.ValidRune(rune(code)) {
+			l.errors = append(l.errors, &errors.InvalidEscape{Pos: pos, Sequence: l.input[position:l.readPosition]})
+			return
+		}
+		out.WriteRune(rune(code))
+	default:
+		l.errors = append(l.errors, &errors.InvalidEscape{Pos: pos, Sequence: l.input[position:l.readPosition]})
+	}
+}
+
+// readRawString reads a string literal between backticks. Its value is the text between them,
+// which may span multiple lines and contains no escape sequences.
+func (l *Lexer) readRawString(pos token.Position) string {
+	// Skip the opening backtick
 	position := l.position + 1
 	for {
 		l.readChar()
-		// end on the closing quote or EOF
-		if l.ch == '"' || l.ch == 0 {
+		if l.ch == '`' {
+			break
+		}
+		if l.ch == 0 {
+			l.errors = append(l.errors, &errors.UnterminatedString{Pos: pos, Raw: true})
 			break
 		}
 	}
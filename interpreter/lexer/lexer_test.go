@@ -111,7 +111,7 @@ let result = add(five, ten);
 }
 
 func TestIntsAndOperators(t *testing.T) {
-	input := `+!-/*5;
+	input := `+!-/ *5;
 5 < 10 > 5;
 5 == 3;
 3 <= 5;
@@ -256,3 +256,525 @@ func TestArraySyntax(t *testing.T) {
 		}
 	}
 }
+
+func TestHashSyntax(t *testing.T) {
+	input := `{"foo": "bar"}
+`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LBRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x + "a b";
+`
+	tests := []struct {
+		expectedType token.TokenType
+		expectedLine int
+		expectedCol  int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 10},
+		{token.IDENT, 2, 3},
+		{token.PLUS, 2, 5},
+		{token.STRING, 2, 7},
+		{token.SEMICOLON, 2, 12},
+		{token.EOF, 3, 1},
+	}
+
+	l := NewFile("test.wx", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos.Filename != "test.wx" {
+			t.Fatalf("tests[%d] - filename wrong. expected=%q, got=%q", i, "test.wx", tok.Pos.Filename)
+		}
+
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedCol {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d", i, tt.expectedLine, tt.expectedCol, tok.Pos.Line, tok.Pos.Column)
+		}
+	}
+}
+
+func TestLoopKeywords(t *testing.T) {
+	input := `while (x) { break; continue; }
+for (i in xs) {}
+`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.WHILE, "while"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.BREAK, "break"},
+		{token.SEMICOLON, ";"},
+		{token.CONTINUE, "continue"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.FOR, "for"},
+		{token.LPAREN, "("},
+		{token.IDENT, "i"},
+		{token.IN, "in"},
+		{token.IDENT, "xs"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestAssignmentOperators(t *testing.T) {
+	input := `x = 1; x += 2; x -= 3; x *= 4; x /= 5;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := `5 3.14 1e-9 2.5E+3 7e3 0.5 1.x 2e 3e+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E+3"},
+		{token.FLOAT, "7e3"},
+		{token.FLOAT, "0.5"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "x"},
+		{token.INT, "2"},
+		{token.IDENT, "e"},
+		{token.INT, "3"},
+		{token.IDENT, "e"},
+		{token.PLUS, "+"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestEllipsis(t *testing.T) {
+	input := `fn(...rest) .. .`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RPAREN, ")"},
+		{token.ILLEGAL, "."},
+		{token.ILLEGAL, "."},
+		{token.ILLEGAL, "."},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	input := `a % b & c | ~d << 1 >> 2 <= 3 >= 4 < 5 > 6`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "b"},
+		{token.AMPERSAND, "&"},
+		{token.IDENT, "c"},
+		{token.PIPE, "|"},
+		{token.TILDE, "~"},
+		{token.IDENT, "d"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.INT, "1"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.INT, "2"},
+		{token.LTEQ, "<="},
+		{token.INT, "3"},
+		{token.GTEQ, ">="},
+		{token.INT, "4"},
+		{token.LT, "<"},
+		{token.INT, "5"},
+		{token.GT, ">"},
+		{token.INT, "6"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	input := `a && b || c and d OR e & f | g`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.AND, "and"},
+		{token.IDENT, "d"},
+		{token.OR, "OR"},
+		{token.IDENT, "e"},
+		{token.AMPERSAND, "&"},
+		{token.IDENT, "f"},
+		{token.PIPE, "|"},
+		{token.IDENT, "g"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// a line comment
+let x = 5; // trailing
+/* a block
+   comment */ x /**/ / 2 /*/ still a comment */;
+x // at the end`
+
+	tests := []struct {
+		expectedType     token.TokenType
+		expectedLiteral  string
+		expectedComments []string
+	}{
+		{token.LET, "let", []string{"// a line comment"}},
+		{token.IDENT, "x", nil},
+		{token.ASSIGN, "=", nil},
+		{token.INT, "5", nil},
+		{token.SEMICOLON, ";", nil},
+		{token.IDENT, "x", []string{"// trailing", "/* a block\n   comment */"}},
+		{token.SLASH, "/", []string{"/**/"}},
+		{token.INT, "2", nil},
+		{token.SEMICOLON, ";", []string{"/*/ still a comment */"}},
+		{token.IDENT, "x", nil},
+		{token.EOF, "", []string{"// at the end"}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if len(tok.Comments) != len(tt.expectedComments) {
+			t.Fatalf("tests[%d] - comments wrong. expected=%q, got=%q", i, tt.expectedComments, tok.Comments)
+		}
+		for j, comment := range tt.expectedComments {
+			if tok.Comments[j] != comment {
+				t.Fatalf("tests[%d] - comment wrong. expected=%q, got=%q", i, comment, tok.Comments[j])
+			}
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Fatalf("lexer has errors: %v", l.Errors())
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	l := New("x /* not\nterminated")
+
+	if tok := l.NextToken(); tok.Type != token.IDENT {
+		t.Fatalf("tokentype wrong. expected=%q, got=%q", token.IDENT, tok.Type)
+	}
+	if tok := l.NextToken(); tok.Type != token.EOF {
+		t.Fatalf("tokentype wrong. expected=%q, got=%q", token.EOF, tok.Type)
+	}
+
+	if len(l.Errors()) != 1 {
+		t.Fatalf("wrong number of errors. expected=1, got=%d", len(l.Errors()))
+	}
+
+	expected := "1:3: [UnterminatedComment] Block comment is not terminated"
+	if l.Errors()[0].Error() != expected {
+		t.Fatalf("wrong error. expected=%q, got=%q", expected, l.Errors()[0].Error())
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"foo"`, "foo"},
+		{`""`, ""},
+		{`"a\nb"`, "a\nb"},
+		{`"a\tb\rc"`, "a\tb\rc"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"caf\u{e9}"`, "café"},
+		{`"\u{1F600}"`, "\U0001F600"},
+		{`"café"`, "café"},
+		{"`raw \\n \"string\"`", `raw \n "string"`},
+		{"`first\nsecond`", "first\nsecond"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, token.STRING, tok.Type)
+		}
+
+		if tok.Literal != tt.expected {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expected, tok.Literal)
+		}
+
+		if len(l.Errors()) != 0 {
+			t.Fatalf("tests[%d] - lexer has errors: %v", i, l.Errors())
+		}
+
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Fatalf("tests[%d] - string not consumed. got=%q", i, tok.Literal)
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`x = "abc`, "1:5: [UnterminatedString] String literal is not terminated"},
+		{"\"abc\nx", "1:1: [UnterminatedString] String literal is not terminated"},
+		{`"abc\`, "1:1: [UnterminatedString] String literal is not terminated"},
+		{"`abc\nx", "1:1: [UnterminatedString] String literal is not terminated"},
+		{`x = "a ${x`, "1:5: [UnterminatedString] String literal is not terminated"},
+		{"\"a ${x} b\nc", "1:1: [UnterminatedString] String literal is not terminated"},
+		{`"a\qb"`, `1:3: [InvalidEscape] Invalid escape sequence \q in string literal`},
+		{`"\u"`, `1:2: [InvalidEscape] Invalid escape sequence \u in string literal`},
+		{`"\u{e9"`, `1:2: [InvalidEscape] Invalid escape sequence \u{e9 in string literal`},
+		{`"\u{}"`, `1:2: [InvalidEscape] Invalid escape sequence \u{} in string literal`},
+		{`"\u{110000}"`, `1:2: [InvalidEscape] Invalid escape sequence \u{110000} in string literal`},
+		{`"\u{D800}"`, `1:2: [InvalidEscape] Invalid escape sequence \u{D800} in string literal`},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		if len(l.Errors()) != 1 {
+			t.Fatalf("tests[%d] - wrong number of errors. expected=1, got=%v", i, l.Errors())
+		}
+
+		if l.Errors()[0].Error() != tt.expected {
+			t.Fatalf("tests[%d] - wrong error. expected=%q, got=%q", i, tt.expected, l.Errors()[0].Error())
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := `let größe = "😀 straße";
+größe + π;
+€`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1, 1},
+		{token.IDENT, "größe", 1, 5},
+		{token.ASSIGN, "=", 1, 11},
+		{token.STRING, "😀 straße", 1, 13},
+		{token.SEMICOLON, ";", 1, 23},
+		{token.IDENT, "größe", 2, 1},
+		{token.PLUS, "+", 2, 7},
+		{token.IDENT, "π", 2, 9},
+		{token.SEMICOLON, ";", 2, 10},
+		{token.ILLEGAL, "€", 3, 1},
+		{token.EOF, "", 3, 2},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%s", i, tt.expectedLine, tt.expectedColumn, tok.Pos)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"sum ${a + b}, ${ {"k": "${c}"}["k"] }!" "\${x} $y"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING_HEAD, "sum "},
+		{token.IDENT, "a"},
+		{token.PLUS, "+"},
+		{token.IDENT, "b"},
+		{token.STRING_MIDDLE, ", "},
+		{token.LBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.STRING_HEAD, ""},
+		{token.IDENT, "c"},
+		{token.STRING_TAIL, ""},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.STRING_TAIL, "!"},
+		{token.STRING, "${x} $y"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Fatalf("lexer has errors: %v", l.Errors())
+	}
+}
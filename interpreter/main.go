@@ -1,18 +1,244 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
+	"waixg/compiler"
+	"waixg/evaluator"
+	"waixg/interpreter/ast"
+	"waixg/interpreter/lexer"
+	"waixg/interpreter/object"
+	"waixg/interpreter/parser"
 	"waixg/interpreter/repl"
+	"waixg/vm"
 )
 
+// Exit codes of the command line interface
+const (
+	exitOK    = 0
+	exitError = 1 // the program could not be parsed or failed at runtime
+	exitUsage = 2 // the command line was invalid
+)
+
+// Engines that can run a program
+const (
+	engineEval = "eval" // the tree-walking evaluator
+	engineVM   = "vm"   // the bytecode compiler and virtual machine
+)
+
+const usage = `Usage:
+  waixg                 start the interactive playground
+  waixg repl            start the interactive playground
+  waixg run <file>      run a script file
+  waixg -e <source>     evaluate source and print the result
+
+Everything runs on the tree-walking evaluator unless -engine vm is given.
+Flags can come before or after the command and the file, e.g. waixg run main.wx -engine vm.
+
+Flags:
+`
+
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, in io.Reader, out io.Writer, errOut io.Writer) int {
+	flags := flag.NewFlagSet("waixg", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(errOut, usage)
+		flags.PrintDefaults()
+	}
+	source := flags.String("e", "", "evaluate `source` and print the result")
+	engine := flags.String("engine", engineEval, "`engine` running programs: eval or vm")
+
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if *engine != engineEval && *engine != engineVM {
+		_, _ = fmt.Fprintf(errOut, "unknown engine %q\n", *engine)
+		flags.Usage()
+		return exitUsage
+	}
+
+	// -e '' evaluates the empty program rather than starting the REPL
+	if isFlagSet(flags, "e") {
+		if len(positional) != 0 {
+			flags.Usage()
+			return exitUsage
+		}
+		return evalSource(*engine, "-e", *source, out, errOut, true)
+	}
+
+	command := ""
+	if len(positional) > 0 {
+		command = positional[0]
+	}
+
+	switch command {
+	case "", "repl":
+		if len(positional) > 1 {
+			flags.Usage()
+			return exitUsage
+		}
+		startRepl(*engine, in, out)
+		return exitOK
+	case "run":
+		if len(positional) != 2 {
+			flags.Usage()
+			return exitUsage
+		}
+		return runFile(*engine, positional[1], out, errOut)
+	default:
+		_, _ = fmt.Fprintf(errOut, "unknown command %q\n", command)
+		flags.Usage()
+		return exitUsage
+	}
+}
+
+// parseArgs parses the flags in args, which may come before, between or after the positional arguments,
+// and returns the positional arguments. Everything after "--" is positional.
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		consumed := len(args) - flags.NArg()
+		if consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, flags.Args()...), nil
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// isFlagSet reports whether the flag name was given on the command line, even with an empty value
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func startRepl(engine string, in io.Reader, out io.Writer) {
+	if name := userName(); name != "" {
+		_, _ = fmt.Fprintf(out, "Hello %s! Welcome to the playground!\n\n", name)
+	} else {
+		_, _ = fmt.Fprint(out, "Hello! Welcome to the playground!\n\n")
+	}
+	if engine == engineVM {
+		repl.StartVM(in, out)
+	} else {
+		repl.Start(in, out)
+	}
+}
+
+// userName returns the full name of the current user, their login name if the full name is unknown,
+// or an empty string if the user can't be looked up
+func userName() string {
 	osUser, err := user.Current()
 	if err != nil {
-		panic(err)
+		return ""
+	}
+	if osUser.Name != "" {
+		return osUser.Name
+	}
+	return osUser.Username
+}
+
+func runFile(engine string, path string, out io.Writer, errOut io.Writer) int {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		_, _ = fmt.Fprintf(errOut, "%s\n", err)
+		return exitError
+	}
+
+	return evalSource(engine, path, string(src), out, errOut, false)
+}
+
+// evalSource lexes, parses and runs src as a whole program with the given engine.
+// Parser and runtime errors are written to errOut, the value of the program is only written to out
+// if printResult is set.
+func evalSource(engine string, filename string, src string, out io.Writer, errOut io.Writer, printResult bool) int {
+	p := parser.New(lexer.NewFile(filename, src))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		for _, err := range p.Errors() {
+			_, _ = fmt.Fprintf(errOut, "%s\n", err)
+		}
+		return exitError
+	}
+
+	evaluated, err := safeRun(func() object.Object {
+		if engine == engineVM {
+			return runBytecode(program, out)
+		}
+		env := object.NewEnvironment()
+		opts := evaluator.DefaultOptions
+		opts.Output = out
+		evaluator.Configure(env, opts)
+		return evaluator.Eval(program, env)
+	})
+	if err != nil {
+		_, _ = fmt.Fprintf(errOut, "%s\n", err)
+		return exitError
+	}
+	if errObj, ok := evaluated.(*object.Error); ok {
+		_, _ = fmt.Fprintf(errOut, "%s\n", errObj.Inspect())
+		return exitError
+	}
+
+	if printResult && evaluated != nil {
+		_, _ = fmt.Fprintf(out, "%s\n", evaluated.Inspect())
+	}
+
+	return exitOK
+}
+
+// safeRun calls run, turning a panic inside the engine into an error like the REPL does,
+// so a bug in the engine ends the program with an error message rather than a stack trace.
+func safeRun(run func() object.Object) (result object.Object, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("PANIC: %v", r)
+		}
+	}()
+
+	return run(), nil
+}
+
+// runBytecode compiles program and runs it on the virtual machine, with puts writing to out.
+// Like evaluator.Eval, it returns the value of the program or the error that stopped it.
+func runBytecode(program *ast.Program, out io.Writer) object.Object {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return err.(*object.Error)
+	}
+
+	machine := vm.New(comp.Bytecode())
+	machine.SetOutput(out)
+	if err := machine.Run(); err != nil {
+		return err.(*object.Error)
 	}
 
-	fmt.Printf("Hello %s! Welcome to the playground!\n\n", osUser.Name)
-	repl.Start(os.Stdin, os.Stdout)
+	return machine.LastPoppedStackElem()
 }
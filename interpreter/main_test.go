@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"waixg/interpreter/object"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "main.wx")
+	if err := os.WriteFile(script, []byte("puts(\"hi\")\nlet x = 1 + 2;\nputs(x)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(dir, "broken.wx")
+	if err := os.WriteFile(broken, []byte("let x = 1 +"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.wx")
+
+	tests := []struct {
+		args         []string
+		expectedCode int
+		expectedOut  string
+		expectedErr  string // a prefix of what is written to stderr
+	}{
+		{[]string{"-e", "1 + 2"}, exitOK, "3\n", ""},
+		{[]string{"-engine", "vm", "-e", "1 + 2"}, exitOK, "3\n", ""},
+		{[]string{"-e", "1 + 2", "-engine", "vm"}, exitOK, "3\n", ""},
+		{[]string{"-e", `puts("hi")`}, exitOK, "hi\nnull\n", ""},
+		{[]string{"-engine", "vm", "-e", `puts("hi")`}, exitOK, "hi\nnull\n", ""},
+		{[]string{"-e", ""}, exitOK, "", ""},
+		{[]string{"-e", "1 +"}, exitError, "", "-e:1:4: [NoPrefixParseFnError] No prefix parse function for EOF found\n"},
+		{[]string{"-e", "1 / 0"}, exitError, "", "ERROR: -e:1:3: division by zero: 1 / 0\n"},
+		{[]string{"-engine", "vm", "-e", "1 / 0"}, exitError, "", "ERROR: -e:1:3: division by zero: 1 / 0\n"},
+		{[]string{"run", script}, exitOK, "hi\n3\n", ""},
+		{[]string{"run", script, "-engine", "vm"}, exitOK, "hi\n3\n", ""},
+		{[]string{"-engine", "vm", "run", script}, exitOK, "hi\n3\n", ""},
+		{[]string{"run", broken}, exitError, "", broken + ":1:12: [NoPrefixParseFnError] No prefix parse function for EOF found\n"},
+		{[]string{"run", missing}, exitError, "", "open " + missing + ": no such file or directory\n"},
+		{[]string{"run", "--", script}, exitOK, "hi\n3\n", ""},
+		{[]string{"run"}, exitUsage, "", "Usage:"},
+		{[]string{"run", script, "extra"}, exitUsage, "", "Usage:"},
+		{[]string{"-e", "1", "extra"}, exitUsage, "", "Usage:"},
+		{[]string{"-engine", "js", "-e", "1"}, exitUsage, "", "unknown engine \"js\"\nUsage:"},
+		{[]string{"frobnicate"}, exitUsage, "", "unknown command \"frobnicate\"\nUsage:"},
+		{[]string{"-nope"}, exitUsage, "", "flag provided but not defined: -nope\nUsage:"},
+		{[]string{"-h"}, exitOK, "", "Usage:"},
+	}
+
+	for _, tt := range tests {
+		var out, errOut bytes.Buffer
+		code := run(tt.args, strings.NewReader(""), &out, &errOut)
+
+		if code != tt.expectedCode {
+			t.Errorf("%q: wrong exit code. expected=%d, got=%d (stderr %q)", tt.args, tt.expectedCode, code, errOut.String())
+		}
+		if out.String() != tt.expectedOut {
+			t.Errorf("%q: wrong stdout. expected=%q, got=%q", tt.args, tt.expectedOut, out.String())
+		}
+		if !strings.HasPrefix(errOut.String(), tt.expectedErr) || (tt.expectedErr == "" && errOut.Len() != 0) {
+			t.Errorf("%q: wrong stderr. expected prefix %q, got=%q", tt.args, tt.expectedErr, errOut.String())
+		}
+	}
+}
+
+func TestRunRepl(t *testing.T) {
+	tests := [][]string{
+		nil,
+		{"repl"},
+		{"-engine", "vm"},
+		{"repl", "-engine", "vm"},
+	}
+
+	for _, args := range tests {
+		var out, errOut bytes.Buffer
+		code := run(args, strings.NewReader("let x = 20;\nputs(x + 1)\n"), &out, &errOut)
+
+		if code != exitOK {
+			t.Errorf("%q: wrong exit code. expected=%d, got=%d (stderr %q)", args, exitOK, code, errOut.String())
+		}
+		if !strings.Contains(out.String(), ">> 21\nnull\n>> ") {
+			t.Errorf("%q: puts output is missing from the REPL output, got=%q", args, out.String())
+		}
+	}
+}
+
+func TestSafeRun(t *testing.T) {
+	result, err := safeRun(func() object.Object {
+		var arr *object.Array
+		return arr.Elements[0]
+	})
+	if result != nil || err == nil || !strings.HasPrefix(err.Error(), "PANIC: runtime error: invalid memory address") {
+		t.Errorf("panic not turned into an error. got result=%v, err=%v", result, err)
+	}
+
+	result, err = safeRun(func() object.Object { return &object.Integer{Value: 1} })
+	if err != nil || result.Inspect() != "1" {
+		t.Errorf("wrong result. got result=%v, err=%v", result, err)
+	}
+}
@@ -3,21 +3,33 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
 	"strings"
+	"waixg/code"
 	"waixg/interpreter/ast"
+	"waixg/interpreter/token"
 )
 
 type ObjectType string
 
 const (
-	IntegerObj     = "INTEGER"
-	BooleanObj     = "BOOLEAN"
-	NullObj        = "NULL"
-	ReturnValueObj = "RETURN_VALUE"
-	ErrorObj       = "ERROR"
-	FunctionObj    = "FUNCTION"
-	StringObj      = "STRING"
-	BuiltinObj     = "BUILTIN"
+	IntegerObj       = "INTEGER"
+	FloatObj         = "FLOAT"
+	BooleanObj       = "BOOLEAN"
+	NullObj          = "NULL"
+	ReturnValueObj   = "RETURN_VALUE"
+	ErrorObj         = "ERROR"
+	FunctionObj      = "FUNCTION"
+	StringObj        = "STRING"
+	BuiltinObj       = "BUILTIN"
+	ArrayObj         = "ARRAY"
+	HashObj          = "HASH"
+	CompiledFnObj    = "COMPILED_FUNCTION"
+	CompiledScopeObj = "COMPILED_SCOPE"
+	BreakObj         = "BREAK"
+	ContinueObj      = "CONTINUE"
 )
 
 type Object interface {
@@ -32,6 +44,22 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return IntegerObj }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FloatObj }
+
+// Inspect uses the shortest representation of the value, but always marks it as a float,
+// so 3.0 is not shown as the integer 3.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -51,12 +79,48 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return ReturnValueObj }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Break signals a `break` statement to the enclosing loop
+type Break struct {
+	Pos token.Position // where the statement is, to report a break outside of loops
+}
+
+func (b *Break) Type() ObjectType { return BreakObj }
+func (b *Break) Inspect() string  { return "break" }
+
+// Continue signals a `continue` statement to the enclosing loop
+type Continue struct {
+	Pos token.Position // where the statement is, to report a continue outside of loops
+}
+
+func (c *Continue) Type() ObjectType { return ContinueObj }
+func (c *Continue) Inspect() string  { return "continue" }
+
 type Error struct {
 	Err error
+	Pos token.Position // where in the source the error occurred, if known
 }
 
 func (e *Error) Type() ObjectType { return ErrorObj }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Err.Error() }
+
+// Error makes an error object usable as a Go error, e.g. when returned by the virtual machine.
+func (e *Error) Error() string {
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": " + e.Err.Error()
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error, so it can be inspected with errors.Is and errors.As.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Err.Error()
+	}
+	return "ERROR: " + e.Err.Error()
+}
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
@@ -72,6 +136,7 @@ func NewEnvironment() *Environment {
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+	state interface{} // data of the evaluator, only kept by the outermost environment
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -87,26 +152,61 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// State returns the data the evaluator attached to the outermost environment enclosing e, or nil.
+func (e *Environment) State() interface{} {
+	return e.root().state
+}
+
+// SetState attaches data of the evaluator to the outermost environment enclosing e,
+// so it is shared by every environment of a program.
+func (e *Environment) SetState(state interface{}) {
+	e.root().state = state
+}
+
+func (e *Environment) root() *Environment {
+	env := e
+	for env.outer != nil {
+		env = env.outer
+	}
+	return env
+}
+
+// Assign updates the existing binding of name in the innermost environment defining it.
+// It reports false if name is not bound in this or any enclosing environment.
+func (e *Environment) Assign(name string, val Object) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
+	}
+	return false
+}
+
 type Function struct {
+	Name       string // empty for anonymous functions
 	Parameters []*ast.Identifier
+	Defaults   map[string]ast.Expression
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
 
 func (f *Function) Type() ObjectType { return FunctionObj }
 func (f *Function) Inspect() string {
-	var out bytes.Buffer
+	return inspectFunction(f.Parameters, f.Defaults, f.Rest, f.Body)
+}
 
-	var params []string
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
+// inspectFunction renders a function value, the same way for both the evaluator and the virtual machine
+func inspectFunction(parameters []*ast.Identifier, defaults map[string]ast.Expression, rest *ast.Identifier,
+	body *ast.BlockStatement) string {
+	var out bytes.Buffer
 
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.FormatParameters(parameters, defaults, rest))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.String())
+	out.WriteString(body.String())
 	out.WriteString("\n}")
 
 	return out.String()
@@ -119,7 +219,17 @@ type String struct {
 func (s *String) Type() ObjectType { return StringObj }
 func (s *String) Inspect() string  { return s.Value }
 
-type BuiltinFunction func(args ...Object) Object
+// Context is the evaluation a builtin function is called from.
+// It lets builtins call back into the functions of the program, like the function passed to map.
+type Context interface {
+	// Call applies fn, a function or builtin, to args and returns its result.
+	// An error of the call is returned as an *Error and should be passed on by the builtin.
+	Call(fn Object, args ...Object) Object
+	// Output is where builtins like puts write to
+	Output() io.Writer
+}
+
+type BuiltinFunction func(ctx Context, args ...Object) Object
 
 type Builtin struct {
 	Fn BuiltinFunction
@@ -130,3 +240,188 @@ func (b *Builtin) Type() ObjectType { return BuiltinObj }
 func (b *Builtin) Inspect() string {
 	return "builtin function"
 }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ArrayObj }
+func (a *Array) Inspect() string {
+	return inspectNested(a, map[Object]bool{})
+}
+
+// inspectNested inspects obj inside the arrays and hashes in seen.
+// Index assignments let an array or hash contain itself, which is inspected as [...] or {...} instead.
+func inspectNested(obj Object, seen map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		if seen[obj] {
+			return "[...]"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		var out bytes.Buffer
+
+		var elements []string
+		for _, e := range obj.Elements {
+			elements = append(elements, inspectNested(e, seen))
+		}
+
+		out.WriteString("[")
+		out.WriteString(strings.Join(elements, ", "))
+		out.WriteString("]")
+
+		return out.String()
+
+	case *Hash:
+		if seen[obj] {
+			return "{...}"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		var out bytes.Buffer
+
+		var pairs []string
+		for _, pair := range obj.Ordered() {
+			pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), inspectNested(pair.Value, seen)))
+		}
+
+		out.WriteString("{")
+		out.WriteString(strings.Join(pairs, ", "))
+		out.WriteString("}")
+
+		return out.String()
+
+	default:
+		return obj.Inspect()
+	}
+}
+
+// HashKey is the key under which a Hashable object is stored inside a Hash.
+// Two objects with the same type and value produce the same HashKey.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable is implemented by every object that can be used as a key in a Hash.
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+
+	if b.Value {
+		value = 1
+	} else {
+		value = 0
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+// HashPair keeps the original key next to its value, so a Hash can be inspected.
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash maps keys to values and remembers the order in which its keys were added.
+// It is built with NewHash and Set, which keep Keys in step with Pairs.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Keys  []HashKey // the keys of Pairs in insertion order
+}
+
+// NewHash returns an empty hash
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Set stores pair under key. A new key is added after the existing ones, an existing key keeps its place.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if _, ok := h.Pairs[key]; !ok {
+		h.Keys = append(h.Keys, key)
+	}
+	h.Pairs[key] = pair
+}
+
+// Ordered returns the pairs of the hash in insertion order
+func (h *Hash) Ordered() []HashPair {
+	pairs := make([]HashPair, len(h.Keys))
+	for i, key := range h.Keys {
+		pairs[i] = h.Pairs[key]
+	}
+	return pairs
+}
+
+func (h *Hash) Type() ObjectType { return HashObj }
+func (h *Hash) Inspect() string {
+	return inspectNested(h, map[Object]bool{})
+}
+
+// CompiledFunction is a function compiled to bytecode, stored in the constant pool
+type CompiledFunction struct {
+	Name          string // empty for anonymous functions
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	NumDefaults   int  // number of trailing parameters with a default value
+	HasRest       bool // whether the local after the parameters collects extra arguments
+	LocalNames    []string
+	SourceMap     map[int]token.Position // instruction offset to source position
+	Literal       *ast.FunctionLiteral   // the compiled source, nil for the main program
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return CompiledFnObj }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// CompiledScope describes the local variables of a block with a scope of its own, like the body of a for loop.
+// It is stored in the constant pool.
+type CompiledScope struct {
+	LocalNames []string
+}
+
+func (cs *CompiledScope) Type() ObjectType { return CompiledScopeObj }
+func (cs *CompiledScope) Inspect() string {
+	return fmt.Sprintf("CompiledScope[%p]", cs)
+}
+
+// Scope holds the local variables of a single call of a compiled function, or of a single iteration of a for loop
+type Scope struct {
+	Names  []string // names of the locals, for error messages
+	Locals []Object
+	Outer  *Scope // scope the called closure was created in, or the scope around the loop
+}
+
+// Closure is a compiled function together with the scope it was created in.
+// It has the same type as a Function, since both behave the same for the user.
+type Closure struct {
+	Fn    *CompiledFunction
+	Scope *Scope
+}
+
+func (c *Closure) Type() ObjectType { return FunctionObj }
+func (c *Closure) Inspect() string {
+	lit := c.Fn.Literal
+	if lit == nil {
+		return fmt.Sprintf("Closure[%p]", c)
+	}
+	return inspectFunction(lit.Parameters, lit.Defaults, lit.Rest, lit.Body)
+}
@@ -13,7 +13,7 @@ const enableTraces = false
 // Precedence is the precedence of operators
 //
 // The higher the value, the higher the precedence (the more important the operator).
-// The highest precedence is `CALL` with a value of 7 and represents function calls
+// The highest precedence is `CALL` with a value of 15 and represents function calls
 // The lowest precedence is `LOWEST` with a value of 1 and is the default value if no operator is found
 type Precedence int
 
@@ -21,29 +21,51 @@ type Precedence int
 const (
 	_ Precedence = iota
 	LOWEST
+	ASSIGN      // = or +=
+	OR          // || or or
+	AND         // && or and
 	EQUALS      // ==
 	LESSGREATER // > or <
+	BITWISE_OR  // |
+	BITWISE_XOR // ~
+	BITWISE_AND // &
+	SHIFT       // << or >>
 	SUM         // +
-	PRODUCT     // *
+	PRODUCT     // * or %
 	EXPONENT    // ^
-	PREFIX      // -X or !X
+	PREFIX      // -X, !X or ~X
 	CALL        // myFunction(X)
 )
 
 // Precedence table associating token types with their precedence
 var precedences = map[token.TokenType]Precedence{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.GTEQ:     LESSGREATER,
-	token.LTEQ:     LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.HAT:      EXPONENT,
-	token.LPAREN:   CALL,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+
+	token.OR:          OR,
+	token.AND:         AND,
+	token.EQ:          EQUALS,
+	token.NOT_EQ:      EQUALS,
+	token.LT:          LESSGREATER,
+	token.GT:          LESSGREATER,
+	token.GTEQ:        LESSGREATER,
+	token.LTEQ:        LESSGREATER,
+	token.PIPE:        BITWISE_OR,
+	token.TILDE:       BITWISE_XOR,
+	token.AMPERSAND:   BITWISE_AND,
+	token.SHIFT_LEFT:  SHIFT,
+	token.SHIFT_RIGHT: SHIFT,
+	token.PLUS:        SUM,
+	token.MINUS:       SUM,
+	token.SLASH:       PRODUCT,
+	token.ASTERISK:    PRODUCT,
+	token.PERCENT:     PRODUCT,
+	token.HAT:         EXPONENT,
+	token.LPAREN:      CALL,
+	token.LBRACKET:    CALL,
 }
 
 type (
@@ -55,7 +77,8 @@ type (
 type Parser struct {
 	l *lexer.Lexer
 
-	errors []error
+	errors      []error
+	lexerErrors int // number of errors of the lexer already added to errors
 
 	curToken  token.Token
 	peekToken token.Token
@@ -73,15 +96,19 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRING_HEAD, p.parseInterpolatedString)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -89,13 +116,27 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.HAT, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.TILDE, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.GTEQ, p.parseInfixExpression)
 	p.registerInfix(token.LTEQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 
 	// Read two tokens, so curToken and peekToken are both set
 	// curToken will be the first token in the input
@@ -125,6 +166,12 @@ func (p *Parser) addError(err error) {
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	// errors of the lexer are reported together with the errors of the parser
+	if lexerErrors := p.l.Errors(); len(lexerErrors) > p.lexerErrors {
+		p.errors = append(p.errors, lexerErrors[p.lexerErrors:]...)
+		p.lexerErrors = len(lexerErrors)
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -148,6 +195,14 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
 
 	default:
 		return p.parseExpressionStatement()
@@ -171,6 +226,11 @@ func (p *Parser) parseLetStatement() ast.Statement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
+	// remember the name of a function, so errors can refer to it
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fn.Name = stmt.Name.Value
+	}
+
 	for p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -195,6 +255,7 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 		return true
 	} else {
 		p.addError(&errors.PeekTypeMismatch{
+			Pos:      p.peekToken.Pos,
 			Expected: t,
 			Actual:   p.peekToken.Type,
 		})
@@ -209,7 +270,103 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	for p.curTokenIs(token.SEMICOLON) {
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	if enableTraces {
+		defer untrace(trace("parseWhileStatement"))
+	}
+
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	// we expect a `(` after the `while` keyword
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	// parseBlockStatement() will consume the trailing `}`
+	stmt.Body = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	if enableTraces {
+		defer untrace(trace("parseForStatement"))
+	}
+
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	// we expect `(x in iterable)` after the `for` keyword
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	// parseBlockStatement() will consume the trailing `}`
+	stmt.Body = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseBreakStatement() ast.Statement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() ast.Statement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -240,6 +397,7 @@ func (p *Parser) parseExpression(precedence Precedence) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.addError(&errors.NoPrefixParseFnError{
+			Pos:       p.curToken.Pos,
 			TokenType: p.curToken.Type,
 		})
 		return nil
@@ -273,6 +431,26 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		p.addError(&errors.InvalidIntegerLiteral{
+			Pos:     p.curToken.Pos,
+			Literal: p.curToken.Literal,
+		})
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	if enableTraces {
+		defer untrace(trace("parseFloatLiteral"))
+	}
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.addError(&errors.InvalidFloatLiteral{
+			Pos:     p.curToken.Pos,
 			Literal: p.curToken.Literal,
 		})
 		return nil
@@ -320,14 +498,57 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 		Operator: p.curToken.Literal,
 		Left:     left,
 	}
+	if p.curTokenIs(token.AND) || p.curTokenIs(token.OR) {
+		// the keywords and and or are the same operators as && and ||
+		expression.Operator = string(p.curToken.Type)
+	}
 
 	precedence := p.curPrecedence()
+	if p.curTokenIs(token.HAT) {
+		// ^ is right-associative, parsing the right operand with a lower precedence lets it take
+		// further ^ operators, so 2^3^2 is 2^(3^2)
+		precedence--
+	}
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 
 	return expression
 }
 
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	if enableTraces {
+		defer untrace(trace("parseAssignExpression"))
+	}
+
+	// the error of a target which failed to parse, like `fn = 1`, has been reported already
+	if target == nil {
+		return nil
+	}
+
+	// only variables and elements of arrays or hashes can be assigned to
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.addError(&errors.InvalidAssignmentTarget{
+			Pos:    p.curToken.Pos,
+			Target: target.String(),
+		})
+		return nil
+	}
+
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	// assignments are right-associative, `a = b = 1` assigns 1 to b first
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+
+	return expression
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
@@ -427,9 +648,11 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	// parse the function parameters (list of identifiers/expressions)
+	// parse the function parameters (list of identifiers with optional defaults and a rest parameter)
 	// parseFunctionParameters() will consume the trailing `)`
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 
 	// we expect a `{` after the parameters
 	if !p.expectPeek(token.LBRACE) {
@@ -443,38 +666,63 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseFunctionParameters parses a parameter list like `(a, b = 2, ...rest)` into lit.
+// Parameters with a default value have to come after the ones without,
+// the rest parameter has to be the last one.
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
 
 	// if we have a `)` after the `(`, we have no parameters
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	// advance the tokens
-	p.nextToken()
+	for {
+		// a `...` starts the rest parameter, which has to be followed by the closing `)`
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
 
-	// parse the first parameter
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
 
-	// if we have a `,` after the first parameter, we have more parameters
-	for p.peekTokenIs(token.COMMA) {
-		// consume the `,`
-		p.nextToken()
-		// advance the token to the next parameter
-		p.nextToken()
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
-	}
+		lit.Parameters = append(lit.Parameters, ident)
+
+		if p.peekTokenIs(token.ASSIGN) {
+			// consume the `=` and advance to the default value
+			p.nextToken()
+			p.nextToken()
+
+			if lit.Defaults == nil {
+				lit.Defaults = make(map[string]ast.Expression)
+			}
+			lit.Defaults[ident.Value] = p.parseExpression(LOWEST)
+		} else if len(lit.Defaults) > 0 {
+			p.addError(&errors.InvalidParameter{
+				Pos:    ident.Pos(),
+				Name:   ident.Value,
+				Reason: "without default value follows a parameter with default value",
+			})
+			return false
+		}
 
-	// we expect a `)` after the last parameter
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+		// if we have a `,` after the parameter, we have more parameters
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
-	return identifiers
+	// we expect a `)` after the last parameter
+	return p.expectPeek(token.RPAREN)
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -495,6 +743,34 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseInterpolatedString parses the parts of a string from its STRING_HEAD to its STRING_TAIL.
+// Empty string literals between the parts are left out.
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+
+	for {
+		if p.curToken.Literal != "" {
+			str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+		}
+		if p.curTokenIs(token.STRING_TAIL) {
+			return str
+		}
+
+		p.nextToken()
+		exp := p.parseExpression(LOWEST)
+		if exp == nil {
+			return nil
+		}
+		str.Parts = append(str.Parts, exp)
+
+		if p.peekTokenIs(token.STRING_MIDDLE) {
+			p.nextToken()
+		} else if !p.expectPeek(token.STRING_TAIL) {
+			return nil
+		}
+	}
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
@@ -526,3 +802,98 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 
 	return list
 }
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	if enableTraces {
+		defer untrace(trace("parseIndexExpression"))
+	}
+
+	tok := p.curToken
+
+	// a `:` right after the `[` starts a slice without a start
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(&ast.SliceExpression{Token: tok, Left: left})
+	}
+
+	exp := &ast.IndexExpression{Token: tok, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(&ast.SliceExpression{Token: tok, Left: left, Start: exp.Index})
+	}
+
+	// we expect a `]` after the index
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+// parseSliceExpression parses the rest of a slice after the `:` following its start.
+// The end and the step are both optional, as is the `:` before the step.
+func (p *Parser) parseSliceExpression(exp *ast.SliceExpression) ast.Expression {
+	if enableTraces {
+		defer untrace(trace("parseSliceExpression"))
+	}
+
+	if !p.peekTokenIs(token.COLON) && !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			exp.Step = p.parseExpression(LOWEST)
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	if enableTraces {
+		defer untrace(trace("parseHashLiteral"))
+	}
+
+	hash := &ast.HashLiteral{Token: p.curToken}
+	hash.Pairs = make(map[ast.Expression]ast.Expression)
+
+	// everything until the next `}` is a list of `key: value` pairs
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		// we expect a `:` between the key and the value
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
+
+		// pairs are separated by `,`, the last one is followed by `}`
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return hash
+}
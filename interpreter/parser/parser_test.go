@@ -116,6 +116,31 @@ func TestReturnStatement(t *testing.T) {
 	}
 }
 
+func TestReturnStatementSemicolons(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedStatements int
+	}{
+		{"return 5;", 1},
+		{"return 5;;", 1},
+		{"return 5; 6", 2},
+		{"return 5; let x = 6;", 2},
+		{"fn() { return 1; 2 }", 1},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != tt.expectedStatements {
+			t.Fatalf("%q: program.Statements does not contain %d statements. got=%d",
+				tt.input, tt.expectedStatements, len(program.Statements))
+		}
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foobar;"
 	p := New(lexer.New(input))
@@ -176,6 +201,37 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e-9;", 1e-9},
+		{"2.5E+3;", 2500},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -184,6 +240,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}{
 		{"!5;", "!", 5},
 		{"-15;", "-", 15},
+		{"~15;", "~", 15},
 		{"!true;", "!", true},
 		{"!false;", "!", false},
 	}
@@ -255,9 +312,19 @@ func TestParsingInfixExpressionsIntegerLiterals(t *testing.T) {
 		{"5 != 5;", 5, "!=", 5},
 		{"5 >= 5;", 5, ">=", 5},
 		{"5 <= 5;", 5, "<=", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 & 5;", 5, "&", 5},
+		{"5 | 5;", 5, "|", 5},
+		{"5 ~ 5;", 5, "~", 5},
+		{"5 << 5;", 5, "<<", 5},
+		{"5 >> 5;", 5, ">>", 5},
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
+		{"true and false", true, "&&", false},
+		{"true or false", true, "||", false},
 	}
 
 	for _, tt := range infixTests {
@@ -310,6 +377,39 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
 		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
 		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"a = b = 1 + 2", "(a = (b = (1 + 2)))"},
+		{"a += b == c", "(a += (b == c))"},
+		{"a[0] *= 2", "((a[0]) *= 2)"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"a && b && c", "((a && b) && c)"},
+		{"a == 1 && b < 2", "((a == 1) && (b < 2))"},
+		{"!a || b", "((!a) || b)"},
+		{"x = a || b", "(x = (a || b))"},
+		{"a or b and c", "(a || (b && c))"},
+		{"a AND b Or c", "((a && b) || c)"},
+		{"2^3^2", "(2 ^ (3 ^ 2))"},
+		{"a * b ^ c ^ d * e", "((a * (b ^ (c ^ d))) * e)"},
+		{"-a ^ b", "((-a) ^ b)"},
+		{"a % b * c", "((a % b) * c)"},
+		{"a + b % c", "(a + (b % c))"},
+		{"a | b ~ c & d", "(a | (b ~ (c & d)))"},
+		{"a & b | c ~ d", "((a & b) | (c ~ d))"},
+		{"a ~ b & c", "(a ~ (b & c))"},
+		{"a & b ~ c", "((a & b) ~ c)"},
+		{"a ~ b | c", "((a ~ b) | c)"},
+		{"a | b ~ c", "(a | (b ~ c))"},
+		{"a ~ b ~ c", "((a ~ b) ~ c)"},
+		{"~a ~ b", "((~a) ~ b)"},
+		{"a & 1 == 0", "((a & 1) == 0)"},
+		{"a | b < c", "((a | b) < c)"},
+		{"1 << a + b", "(1 << (a + b))"},
+		{"a << 1 & b >> 2", "((a << 1) & (b >> 2))"},
+		{"~a & ~b", "((~a) & (~b))"},
+		{"a && b | c", "(a && (b | c))"},
+		{`{"b": a + 1, "a": b * 2, 1: c}`, "{b: (a + 1), a: (b * 2), 1: c}"},
 	}
 
 	for _, tt := range tests {
@@ -604,6 +704,93 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultAndRestParameterParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		defaults int
+		rest     string
+	}{
+		{"fn(a, b = 2) {};", "fn(a, b = 2) {}", 1, ""},
+		{"fn(a = 1, b = a * 2) {};", "fn(a = 1, b = (a * 2)) {}", 2, ""},
+		{"fn(...rest) {};", "fn(...rest) {}", 0, "rest"},
+		{"fn(a, b = 2, ...rest) {};", "fn(a, b = 2, ...rest) {}", 1, "rest"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if function.String() != tt.expected {
+			t.Errorf("function.String() wrong. want %q, got=%q", tt.expected, function.String())
+		}
+
+		if len(function.Defaults) != tt.defaults {
+			t.Errorf("length defaults wrong. want %d, got=%d", tt.defaults, len(function.Defaults))
+		}
+
+		if tt.rest == "" {
+			if function.Rest != nil {
+				t.Errorf("function.Rest is not nil. got=%q", function.Rest)
+			}
+		} else {
+			testIdentifier(t, function.Rest, tt.rest)
+		}
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a = 1, b) {}", "1:11: [InvalidParameter] Parameter b without default value follows a parameter with default value"},
+		{"fn(...rest, a) {}", "1:11: [PeekTypeMismatch] Expected next token to be ), got , instead"},
+		{"fn(1) {}", "1:4: [PeekTypeMismatch] Expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T", stmt.Value)
+	}
+
+	if function.Name != "myFunction" {
+		t.Errorf("function literal name wrong. want 'myFunction', got=%q", function.Name)
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := `add(1, 2 * 3, 4 + 5);`
 
@@ -664,6 +851,60 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStringExpression(t *testing.T) {
+	input := `"sum is ${a + b}!"`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	if len(str.Parts) != 3 {
+		t.Fatalf("wrong number of parts. got=%d", len(str.Parts))
+	}
+
+	if literal, ok := str.Parts[0].(*ast.StringLiteral); !ok || literal.Value != "sum is " {
+		t.Errorf("str.Parts[0] is not %q. got=%s", "sum is ", str.Parts[0])
+	}
+	testInfixExpression(t, str.Parts[1], "a", "+", "b")
+	if literal, ok := str.Parts[2].(*ast.StringLiteral); !ok || literal.Value != "!" {
+		t.Errorf("str.Parts[2] is not %q. got=%s", "!", str.Parts[2])
+	}
+}
+
+func TestParsingInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"${x}"`, "${x}"},
+		{`"a${x}b${y * 2}c"`, "a${x}b${(y * 2)}c"},
+		{`"${"${x}"}"`, "${${x}}"},
+		{`"${ {"a": 1}["a"] }"`, "${({a: 1}[a])}"},
+		{`"${x}" + "y"`, "(${x} + y)"},
+		{`len("${x}")`, "len(${x})"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -688,3 +929,461 @@ func TestParsingArrayLiterals(t *testing.T) {
 	testInfixExpression(t, array.Elements[1], 2, "*", 2)
 	testInfixExpression(t, array.Elements[2], 3, "+", 3)
 }
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp is not ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	expected := map[string]int64{
+		"one":   1,
+		"two":   2,
+		"three": 3,
+	}
+
+	for key, value := range hash.Pairs {
+		literal, ok := key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+		}
+
+		expectedValue := expected[literal.String()]
+
+		testIntegerLiteral(t, value, expectedValue)
+	}
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	input := "{}"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	input := `{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5}`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	tests := map[string]func(ast.Expression){
+		"one": func(e ast.Expression) {
+			testInfixExpression(t, e, 0, "+", 1)
+		},
+		"two": func(e ast.Expression) {
+			testInfixExpression(t, e, 10, "-", 8)
+		},
+		"three": func(e ast.Expression) {
+			testInfixExpression(t, e, 15, "/", 5)
+		},
+	}
+
+	for key, value := range hash.Pairs {
+		literal, ok := key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			continue
+		}
+
+		testFunc, ok := tests[literal.String()]
+		if !ok {
+			t.Errorf("No test function for key %q found", literal.String())
+			continue
+		}
+
+		testFunc(value)
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x 5;", "test.wx:1:7: [PeekTypeMismatch] Expected next token to be =, got INT instead"},
+		{"let x = 1;\n  );", "test.wx:2:3: [NoPrefixParseFnError] No prefix parse function for ) found"},
+		{"99999999999999999999", "test.wx:1:1: [InvalidIntegerLiteral] Could not parse \"99999999999999999999\" as integer"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.NewFile("test.wx", tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { x; break; continue; }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain expected amount of statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 3 {
+		t.Fatalf("body is not 3 statements. got=%d", len(stmt.Body.Statements))
+	}
+
+	if _, ok := stmt.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Errorf("Statements[1] is not ast.BreakStatement. got=%T", stmt.Body.Statements[1])
+	}
+
+	if _, ok := stmt.Body.Statements[2].(*ast.ContinueStatement); !ok {
+		t.Errorf("Statements[2] is not ast.ContinueStatement. got=%T", stmt.Body.Statements[2])
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (x in [1, 2]) { x }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain expected amount of statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Variable, "x") {
+		return
+	}
+
+	if _, ok := stmt.Iterable.(*ast.ArrayLiteral); !ok {
+		t.Fatalf("stmt.Iterable is not ast.ArrayLiteral. got=%T", stmt.Iterable)
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statements. got=%d", len(stmt.Body.Statements))
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		target   string
+		operator string
+	}{
+		{"x = 5;", "x", "="},
+		{"x += 5;", "x", "+="},
+		{"x -= 5;", "x", "-="},
+		{"x *= 5;", "x", "*="},
+		{"x /= 5;", "x", "/="},
+		{"xs[1] = 5;", "(xs[1])", "="},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T", stmt.Expression)
+		}
+
+		if exp.Target.String() != tt.target {
+			t.Errorf("exp.Target is not %s. got=%s", tt.target, exp.Target.String())
+		}
+
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator is not %s. got=%s", tt.operator, exp.Operator)
+		}
+
+		testLiteralExpression(t, exp.Value, 5)
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	p := New(lexer.New("1 = 2;"))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 parser error, got=%d", len(errors))
+	}
+
+	expected := "1:3: [InvalidAssignmentTarget] Cannot assign to 1"
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestAssignmentToUnparsableTarget(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn = 1", "1:4: [PeekTypeMismatch] Expected next token to be (, got = instead"},
+		{"if = 1", "1:4: [PeekTypeMismatch] Expected next token to be (, got = instead"},
+		{"let fn = true;", "1:5: [PeekTypeMismatch] Expected next token to be IDENT, got FUNCTION instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("%q: expected parser errors", tt.input)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `
+// the answer
+let x = /* inline */ 42; // trailing
+x /* divided */ / 2;
+`
+	program := New(lexer.New(input)).ParseProgram()
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	if program.String() != "let x = 42;(x / 2)" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	p := New(lexer.New("/* never closed\nlet x = 1;"))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 parser error, got=%d", len(errors))
+	}
+
+	expected := "1:1: [UnterminatedComment] Block comment is not terminated"
+	if errors[0].Error() != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestStringLiteralErrors(t *testing.T) {
+	p := New(lexer.New("let s = \"a\\qb\";\nlet t = \"open"))
+	p.ParseProgram()
+
+	expected := []string{
+		`1:11: [InvalidEscape] Invalid escape sequence \q in string literal`,
+		"2:9: [UnterminatedString] String literal is not terminated",
+	}
+
+	errors := p.Errors()
+	if len(errors) != len(expected) {
+		t.Fatalf("expected %d parser errors, got=%v", len(expected), errors)
+	}
+
+	for i, msg := range expected {
+		if errors[i].Error() != msg {
+			t.Errorf("wrong error message. expected=%q, got=%q", msg, errors[i].Error())
+		}
+	}
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`"a ${x y}"`, []string{
+			"1:8: [PeekTypeMismatch] Expected next token to be STRING_TAIL, got IDENT instead",
+			"1:9: [NoPrefixParseFnError] No prefix parse function for STRING_TAIL found",
+		}},
+		{`"a ${}"`, []string{"1:6: [NoPrefixParseFnError] No prefix parse function for STRING_TAIL found"}},
+		{`"a ${x`, []string{
+			"1:1: [UnterminatedString] String literal is not terminated",
+			"1:7: [PeekTypeMismatch] Expected next token to be STRING_TAIL, got EOF instead",
+		}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("%q: expected %d parser errors, got=%v", tt.input, len(tt.expected), errors)
+			continue
+		}
+
+		for i, msg := range tt.expected {
+			if errors[i].Error() != msg {
+				t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, msg, errors[i].Error())
+			}
+		}
+	}
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"xs[1:3]", "(xs[1:3])"},
+		{"xs[:-1]", "(xs[:(-1)])"},
+		{"xs[1:]", "(xs[1:])"},
+		{"xs[:]", "(xs[:])"},
+		{"s[::2]", "(s[::2])"},
+		{"s[::]", "(s[:])"},
+		{"s[1:a + 1:-1]", "(s[1:(a + 1):(-1)])"},
+		{"xs[1:2][0]", "((xs[1:2])[0])"},
+		{"f(xs)[i:]", "(f(xs)[i:])"},
+		{`xs[{"a": 1}["a"]:]`, "(xs[({a: 1}[a]):])"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestSliceExpressionParts(t *testing.T) {
+	p := New(lexer.New("xs[1:2:3]"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	slice, ok := stmt.Expression.(*ast.SliceExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+	}
+
+	testIdentifier(t, slice.Left, "xs")
+	testLiteralExpression(t, slice.Start, 1)
+	testLiteralExpression(t, slice.End, 2)
+	testLiteralExpression(t, slice.Step, 3)
+
+	p = New(lexer.New("xs[:]"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+
+	slice = program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SliceExpression)
+	if slice.Start != nil || slice.End != nil || slice.Step != nil {
+		t.Errorf("left out parts are not nil. got=%v, %v, %v", slice.Start, slice.End, slice.Step)
+	}
+}
+
+func TestSliceExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"xs[1:2:3:4]", "1:9: [PeekTypeMismatch] Expected next token to be ], got : instead"},
+		{"xs[1:2] = 3", "1:9: [InvalidAssignmentTarget] Cannot assign to (xs[1:2])"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("%q: expected parser errors", tt.input)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package repl
+
+import (
+	"io"
+	"waixg/compiler"
+	"waixg/evaluator"
+	"waixg/interpreter/ast"
+	"waixg/interpreter/object"
+	"waixg/vm"
+)
+
+// engine runs the inputs of the REPL one after another, keeping the variables defined by previous inputs
+type engine interface {
+	// run returns the value of program, nil if it has none, or the error that stopped it
+	run(program *ast.Program) object.Object
+}
+
+// evaluatorEngine runs inputs on the tree-walking evaluator
+type evaluatorEngine struct {
+	env *object.Environment
+}
+
+func newEvaluatorEngine(out io.Writer) *evaluatorEngine {
+	env := object.NewEnvironment()
+	opts := evaluator.DefaultOptions
+	opts.Output = out
+	evaluator.Configure(env, opts)
+
+	return &evaluatorEngine{env: env}
+}
+
+func (e *evaluatorEngine) run(program *ast.Program) object.Object {
+	return evaluator.Eval(program, e.env)
+}
+
+// vmEngine compiles inputs and runs them on the virtual machine
+type vmEngine struct {
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+	out         io.Writer
+}
+
+func newVMEngine(out io.Writer) *vmEngine {
+	return &vmEngine{symbolTable: compiler.NewSymbolTable(), out: out}
+}
+
+func (e *vmEngine) run(program *ast.Program) object.Object {
+	comp := compiler.NewWithState(e.symbolTable, e.constants)
+	if err := comp.Compile(program); err != nil {
+		return err.(*object.Error)
+	}
+
+	bytecode := comp.Bytecode()
+	e.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobals(bytecode, e.globals)
+	machine.SetOutput(e.out)
+	err := machine.Run()
+	// globals assigned before a runtime error keep their values, like in the evaluator
+	e.globals = machine.Globals()
+	if err != nil {
+		return err.(*object.Error)
+	}
+
+	return machine.LastPoppedStackElem()
+}
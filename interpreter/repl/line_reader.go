@@ -0,0 +1,152 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/peterh/liner"
+)
+
+// errAborted is returned by a lineReader when the user aborted the current input (Ctrl-C)
+var errAborted = errors.New("input aborted")
+
+// lineReader reads the input of the REPL line by line
+type lineReader interface {
+	// Prompt shows prompt and returns the next line without its line ending.
+	// It returns io.EOF once there is no more input.
+	Prompt(prompt string) (string, error)
+	// AppendHistory remembers a complete input so it can be recalled later
+	AppendHistory(input string)
+	Close()
+}
+
+// newLineReader returns a line editing reader with history if the REPL runs on a terminal,
+// and a plain reader for everything else (pipes, files, tests).
+func newLineReader(in io.Reader, out io.Writer) lineReader {
+	if in == os.Stdin && out == os.Stdout && liner.TerminalSupported() {
+		if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+			return newTerminalReader(historyPath())
+		}
+	}
+
+	return &plainReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+// terminalReader provides line editing and a persistent history navigable with the arrow keys
+type terminalReader struct {
+	state       *liner.State
+	historyFile string
+	// history mirrors the history of state, which can't save multi-line entries
+	history []string
+}
+
+func newTerminalReader(historyFile string) *terminalReader {
+	state := liner.NewLiner()
+	state.SetCtrlCAborts(true)
+	r := &terminalReader{state: state, historyFile: historyFile}
+
+	if historyFile != "" {
+		if f, err := os.Open(historyFile); err == nil {
+			for _, entry := range readHistory(f) {
+				r.AppendHistory(entry)
+			}
+			_ = f.Close()
+		}
+	}
+
+	return r
+}
+
+func (r *terminalReader) Prompt(prompt string) (string, error) {
+	line, err := r.state.Prompt(prompt)
+	if err == liner.ErrPromptAborted {
+		return "", errAborted
+	}
+	return line, err
+}
+
+func (r *terminalReader) AppendHistory(input string) {
+	// like liner, skip repeating the last entry
+	if len(r.history) > 0 && r.history[len(r.history)-1] == input {
+		return
+	}
+
+	r.history = append(r.history, input)
+	if len(r.history) > liner.HistoryLimit {
+		r.history = r.history[len(r.history)-liner.HistoryLimit:]
+	}
+	r.state.AppendHistory(input)
+}
+
+func (r *terminalReader) Close() {
+	defer r.state.Close()
+
+	if r.historyFile == "" {
+		return
+	}
+
+	f, err := os.Create(r.historyFile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	_ = writeHistory(f, r.history)
+}
+
+// writeHistory writes one entry per line. Entries spanning multiple lines are quoted, and so are entries
+// starting with a quote to tell them apart.
+func writeHistory(out io.Writer, entries []string) error {
+	for _, entry := range entries {
+		if strings.Contains(entry, "\n") || strings.HasPrefix(entry, `"`) {
+			entry = strconv.Quote(entry)
+		}
+		if _, err := fmt.Fprintln(out, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readHistory reads the entries written by writeHistory
+func readHistory(in io.Reader) []string {
+	var entries []string
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		entry := scanner.Text()
+		if unquoted, err := strconv.Unquote(entry); err == nil && strings.HasPrefix(entry, `"`) {
+			entry = unquoted
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// plainReader reads lines from any io.Reader, without line editing or history
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *plainReader) Prompt(prompt string) (string, error) {
+	_, _ = fmt.Fprint(r.out, prompt)
+
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+
+	return r.scanner.Text(), nil
+}
+
+func (r *plainReader) AppendHistory(string) {}
+
+func (r *plainReader) Close() {}
@@ -1,30 +1,53 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
-	"waixg/evaluator"
+	"os"
+	"path/filepath"
+	"strings"
+	"waixg/interpreter/ast"
+	"waixg/interpreter/errors"
 	"waixg/interpreter/lexer"
 	"waixg/interpreter/object"
 	"waixg/interpreter/parser"
+	"waixg/interpreter/token"
 )
 
 const PROMPT = ">> "
 
+// CONTINUATION_PROMPT is shown while the current input is incomplete, e.g. inside an open `{`
+const CONTINUATION_PROMPT = ".. "
+
+// HISTORY_FILE is the name of the history file inside the user's home directory
+const HISTORY_FILE = ".waixg_history"
+
+// Start runs the REPL on the tree-walking evaluator
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	start(in, out, newEvaluatorEngine(out))
+}
+
+// StartVM runs the REPL on the bytecode compiler and virtual machine
+func StartVM(in io.Reader, out io.Writer) {
+	start(in, out, newVMEngine(out))
+}
+
+func start(in io.Reader, out io.Writer, e engine) {
+	reader := newLineReader(in, out)
+	defer reader.Close()
 
 	for {
-		_, _ = fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		input, ok := readInput(reader)
+		if !ok {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+		reader.AppendHistory(input)
+
+		l := lexer.New(input)
 		p := parser.New(l)
 
 		program := p.ParseProgram()
@@ -34,7 +57,11 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		evaluated, err := safeRun(e, program)
+		if err != nil {
+			_, _ = fmt.Fprintf(out, "%s\n", err)
+			continue
+		}
 		if evaluated != nil {
 			_, _ = io.WriteString(out, evaluated.Inspect())
 			_, _ = io.WriteString(out, "\n")
@@ -42,6 +69,102 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
+// safeRun runs program, turning a panic inside the engine into an error,
+// so a bug in the engine doesn't end the whole session.
+func safeRun(e engine, program *ast.Program) (result object.Object, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("PANIC: %v", r)
+		}
+	}()
+
+	return e.run(program), nil
+}
+
+// readInput reads lines until they form a complete input, showing the continuation prompt in between.
+// It returns false once the input is exhausted.
+func readInput(reader lineReader) (string, bool) {
+	var lines []string
+
+	prompt := PROMPT
+	for {
+		line, err := reader.Prompt(prompt)
+		if err == errAborted {
+			// discard what has been entered so far and start over
+			lines = nil
+			prompt = PROMPT
+			continue
+		}
+		if err != nil {
+			// hand out a pending incomplete input, the parser will report what is missing
+			return strings.Join(lines, "\n"), len(lines) != 0
+		}
+
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+		if !isIncomplete(input) {
+			return input, true
+		}
+
+		prompt = CONTINUATION_PROMPT
+	}
+}
+
+// isIncomplete reports whether input needs more lines before it can be parsed,
+// because a bracket, block comment or raw string is still open or the last token is an operator expecting a
+// right-hand side.
+func isIncomplete(input string) bool {
+	l := lexer.New(input)
+
+	depth := 0
+	var last token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+		last = tok
+	}
+
+	if depth > 0 {
+		return true
+	}
+
+	for _, err := range l.Errors() {
+		switch err := err.(type) {
+		case *errors.UnterminatedComment:
+			return true
+		case *errors.UnterminatedString:
+			// only raw strings continue on the next line
+			if err.Raw {
+				return true
+			}
+		}
+	}
+
+	switch last.Type {
+	case token.ASSIGN, token.PLUS, token.MINUS, token.ASTERISK, token.SLASH, token.HAT, token.PERCENT,
+		token.AMPERSAND, token.PIPE, token.TILDE, token.SHIFT_LEFT, token.SHIFT_RIGHT,
+		token.BANG, token.EQ, token.NOT_EQ, token.LT, token.GT, token.LTEQ, token.GTEQ, token.AND, token.OR,
+		token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.ASTERISK_ASSIGN, token.SLASH_ASSIGN,
+		token.COMMA, token.COLON:
+		return true
+	default:
+		return false
+	}
+}
+
+// historyPath returns the location of the history file, or an empty string if there is no home directory
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, HISTORY_FILE)
+}
+
 func printParserErrors(out io.Writer, errors []error) {
 	for _, msg := range errors {
 		_, _ = fmt.Fprintf(out, "\t%s\n", msg)
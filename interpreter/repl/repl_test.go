@@ -0,0 +1,138 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2", false},
+		{"let x = 5;", false},
+		{"let add = fn(x, y) {", true},
+		{"let add = fn(x, y) {\n  x + y\n}", false},
+		{"[1, 2,", true},
+		{"add(1,\n2", true},
+		{`{"a": 1`, true},
+		{"1 +", true},
+		{"let x =", true},
+		{"x ==", true},
+		{"x &&", true},
+		{"x ||", true},
+		{"x and", true},
+		{"x or", true},
+		{"x and\ny", false},
+		{"x %", true},
+		{"x &", true},
+		{"x |", true},
+		{"x ~", true},
+		{"x <<", true},
+		{"x >>", true},
+		{"x >>\n2", false},
+		{"}", false},
+		{"1 + 2 /* a comment", true},
+		{"1 + 2 /* a comment */", false},
+		{"1 + 2 // a comment", false},
+		{"let s = `first line", true},
+		{"let s = `first line\nsecond line`", false},
+		{`let s = "not terminated`, false},
+	}
+
+	for _, tt := range tests {
+		if actual := isIncomplete(tt.input); actual != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestStartMultiLineInput(t *testing.T) {
+	input := `let add = fn(x, y) {
+  x +
+    y
+};
+add(1,
+  2)
+`
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">> .. .. .. >> .. 3\n>> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestStartVM(t *testing.T) {
+	input := `let x = 2;
+let f = fn(y) { x * y };
+f(3)
+foo
+break;
+x = x + 1;
+let g = fn() { x / 0 };
+g()
+[x, f(1)]
+`
+	var out bytes.Buffer
+	StartVM(strings.NewReader(input), &out)
+
+	expected := ">> >> >> 6\n" +
+		">> ERROR: 1:1: identifier not found: foo\n" +
+		">> ERROR: 1:1: break outside of loop\n" +
+		">> 3\n" +
+		">> >> ERROR: 1:18: division by zero: 3 / 0\n" +
+		">> [3, 3]\n>> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestStartRuntimeErrors(t *testing.T) {
+	input := `1 / 0
+9223372036854775807 + 1
+2
+`
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">> ERROR: 1:3: division by zero: 1 / 0\n" +
+		">> ERROR: 1:21: integer overflow: 9223372036854775807 + 1\n" +
+		">> 2\n>> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	entries := []string{
+		"1 + 2",
+		"let add = fn(x, y) {\n  x + y\n}",
+		"let s = `first line\n\tsecond line`",
+		`"a string"`,
+		`"unterminated`,
+		`puts("a\nb")`,
+	}
+
+	var file bytes.Buffer
+	if err := writeHistory(&file, entries); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if lines := strings.Count(file.String(), "\n"); lines != len(entries) {
+		t.Errorf("wrong number of lines in the history file. expected=%d, got=%d:\n%s", len(entries), lines, file.String())
+	}
+
+	read := readHistory(&file)
+	if len(read) != len(entries) {
+		t.Fatalf("wrong number of entries. expected=%d, got=%d: %q", len(entries), len(read), read)
+	}
+	for i, entry := range entries {
+		if read[i] != entry {
+			t.Errorf("entry %d wrong. expected=%q, got=%q", i, entry, read[i])
+		}
+	}
+}
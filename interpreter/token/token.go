@@ -1,12 +1,44 @@
 package token
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 type TokenType string
 
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type     TokenType
+	Literal  string
+	Pos      Position // position of the first character of the token
+	Comments []string // comments between the previous token and this one, including their delimiters
+}
+
+// Position describes a location in the source code.
+// Line and Column start at 1, a Position with Line 0 is invalid (unknown).
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String returns the position in the form "file:line:col", "line:col" if no filename is known
+// or "-" if the position is invalid.
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
 }
 
 const (
@@ -16,8 +48,14 @@ const (
 	// Identifiers + literals
 	IDENT  = "IDENT"  // add, foobar, x, y, ...
 	INT    = "INT"    // 1343456
+	FLOAT  = "FLOAT"  // 3.14, 1e-9
 	STRING = "STRING" // "foobar"
 
+	// Parts of an interpolated string like "a ${x} b ${y} c", between them are the tokens of the expressions
+	STRING_HEAD   = "STRING_HEAD"   // "a ${
+	STRING_MIDDLE = "STRING_MIDDLE" // } b ${
+	STRING_TAIL   = "STRING_TAIL"   // } c"
+
 	// Operators
 	ASSIGN   = "=" // Assignment
 	PLUS     = "+" // Addition
@@ -25,6 +63,20 @@ const (
 	ASTERISK = "*" // Multiplication
 	SLASH    = "/" // Division
 	HAT      = "^" // Exponentiation
+	PERCENT  = "%" // Remainder
+
+	// Bitwise operators
+	AMPERSAND   = "&"  // Bitwise and
+	PIPE        = "|"  // Bitwise or
+	TILDE       = "~"  // Bitwise xor, or bitwise not as a prefix
+	SHIFT_LEFT  = "<<" // Left shift
+	SHIFT_RIGHT = ">>" // Right shift
+
+	// Compound assignment operators
+	PLUS_ASSIGN     = "+=" // Addition assignment
+	MINUS_ASSIGN    = "-=" // Subtraction assignment
+	ASTERISK_ASSIGN = "*=" // Multiplication assignment
+	SLASH_ASSIGN    = "/=" // Division assignment
 
 	// Boolean operators
 	BANG   = "!"  // Negation
@@ -34,10 +86,14 @@ const (
 	GT     = ">"  // Greater than
 	LTEQ   = "<=" // Less than or equal to
 	GTEQ   = ">=" // Greater than or equal to
+	AND    = "&&" // Logical and, also the keyword and
+	OR     = "||" // Logical or, also the keyword or
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	ELLIPSIS  = "..."
 
 	LPAREN = "("
 	RPAREN = ")"
@@ -55,16 +111,28 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"and":      AND,
+	"or":       OR,
 }
 
 func LookupIdent(ident string) TokenType {
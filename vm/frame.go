@@ -0,0 +1,28 @@
+package vm
+
+import (
+	"waixg/code"
+	"waixg/interpreter/object"
+)
+
+// Frame is a single call of a closure
+type Frame struct {
+	cl          *object.Closure
+	ip          int // offset of the current instruction or operand
+	start       int // offset of the instruction being executed, to look up its source position
+	basePointer int // stack pointer to restore when the call returns
+	scope       *object.Scope
+}
+
+func NewFrame(cl *object.Closure, basePointer int, scope *object.Scope) *Frame {
+	return &Frame{
+		cl:          cl,
+		ip:          -1,
+		basePointer: basePointer,
+		scope:       scope,
+	}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
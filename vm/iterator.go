@@ -0,0 +1,45 @@
+package vm
+
+import (
+	"waixg/evaluator"
+	"waixg/interpreter/object"
+)
+
+// iterator walks the elements of a for-in loop. It only ever lives on the stack of the VM.
+type iterator struct {
+	elements []object.Object
+	index    int
+}
+
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+func (it *iterator) Inspect() string         { return "iterator" }
+
+// newIterator iterates over the elements of an array, the characters of a string or the keys of a hash
+func newIterator(iterable object.Object) (*iterator, *object.Error) {
+	var elements []object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		elements = iterable.Elements
+	case *object.String:
+		for _, ch := range iterable.Value {
+			elements = append(elements, &object.String{Value: string(ch)})
+		}
+	case *object.Hash:
+		for _, pair := range iterable.Ordered() {
+			elements = append(elements, pair.Key)
+		}
+	default:
+		return nil, evaluator.NewError("cannot iterate over %s", iterable.Type())
+	}
+
+	return &iterator{elements: elements}, nil
+}
+
+func (it *iterator) next() (object.Object, bool) {
+	if it.index >= len(it.elements) {
+		return nil, false
+	}
+	element := it.elements[it.index]
+	it.index++
+	return element, true
+}
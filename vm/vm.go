@@ -0,0 +1,669 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"waixg/code"
+	"waixg/compiler"
+	"waixg/evaluator"
+	"waixg/interpreter/object"
+)
+
+const StackSize = 1 << 16
+
+// MaxDepth is the maximum number of nested function calls, the default of the evaluator.
+// Like in the evaluator, calls in tail position don't count, since they replace their caller.
+const MaxDepth = 10000
+
+// binaryOperators maps the opcodes of binary operations to the operators the evaluator implements
+var binaryOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpPow:          "^",
+	code.OpMod:          "%",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "~",
+	code.OpShiftLeft:    "<<",
+	code.OpShiftRight:   ">>",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLessThan:     "<",
+	code.OpGreaterThan:  ">",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterEqual: ">=",
+}
+
+type VM struct {
+	constants     []object.Object
+	globals       []object.Object
+	globalNames   []string
+	globalIndexes map[string]int // indexes of globalNames, built once names are looked up at runtime
+
+	stack []object.Object
+	sp    int // always points to the next free slot, the top of the stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	lastPopped object.Object
+
+	output io.Writer // where builtins like puts write to
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobals(bytecode, []object.Object{})
+}
+
+// NewWithGlobals creates a virtual machine continuing with the globals of a previous run,
+// e.g. for the next line in a REPL.
+func NewWithGlobals(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		SourceMap:    bytecode.SourceMap,
+	}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0, nil)
+
+	// the main frame doesn't count as a call
+	frames := make([]*Frame, MaxDepth+1)
+	frames[0] = mainFrame
+
+	for len(globals) < len(bytecode.GlobalNames) {
+		globals = append(globals, nil)
+	}
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     globals,
+		globalNames: bytecode.GlobalNames,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		frames:      frames,
+		framesIndex: 1,
+
+		output: os.Stdout,
+	}
+}
+
+// SetOutput sets where builtins like puts write to, os.Stdout by default
+func (vm *VM) SetOutput(w io.Writer) {
+	vm.output = w
+}
+
+// Output returns the writer of builtins like puts, the VM is the object.Context builtins are called with
+func (vm *VM) Output() io.Writer {
+	return vm.output
+}
+
+// Globals returns the global variables, to be passed on to the next run with NewWithGlobals
+func (vm *VM) Globals() []object.Object {
+	return vm.globals
+}
+
+// LastPoppedStackElem returns the value of the last expression statement that was run
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.lastPopped
+}
+
+// Run executes the bytecode.
+// A runtime error of the program is returned as an *object.Error with the position of the failing code.
+func (vm *VM) Run() error {
+	if err := vm.run(0); err != nil {
+		return err
+	}
+	return nil
+}
+
+// run executes instructions until the frames at or above depth have returned or the program ends.
+// Closures called back by builtins run on top of the current frames with their own depth.
+func (vm *VM) run(depth int) *object.Error {
+	for vm.framesIndex > depth && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		frame := vm.currentFrame()
+		frame.ip++
+
+		ip := frame.ip
+		frame.start = ip
+		ins := frame.Instructions()
+		op := code.Opcode(ins[ip])
+
+		var err *object.Error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.push(vm.constants[constIndex])
+
+		case code.OpTrue:
+			err = vm.push(evaluator.TRUE)
+
+		case code.OpFalse:
+			err = vm.push(evaluator.FALSE)
+
+		case code.OpNull:
+			err = vm.push(evaluator.NULL)
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp -= numElements
+
+			err = vm.push(&object.Array{Elements: elements})
+
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			str := evaluator.Interpolate(vm.stack[vm.sp-numParts : vm.sp])
+			vm.sp -= numParts
+			err = vm.push(str)
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			var hash object.Object
+			hash, err = vm.buildHash(vm.sp-numElements, vm.sp)
+			if err == nil {
+				vm.sp -= numElements
+				err = vm.push(hash)
+			}
+
+		case code.OpPop:
+			vm.lastPopped = vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpPow, code.OpMod,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight,
+			code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpGreaterThan, code.OpLessEqual, code.OpGreaterEqual:
+			right := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.EvalInfix(binaryOperators[op], left, right))
+
+		case code.OpMinus:
+			err = vm.pushResult(evaluator.EvalPrefix("-", vm.pop()))
+
+		case code.OpBang:
+			err = vm.pushResult(evaluator.EvalPrefix("!", vm.pop()))
+
+		case code.OpBitNot:
+			err = vm.pushResult(evaluator.EvalPrefix("~", vm.pop()))
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.EvalIndex(left, index))
+
+		case code.OpSlice:
+			step := vm.pop()
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.EvalSlice(left, start, end, step))
+
+		case code.OpSetIndex:
+			compoundOp := code.Opcode(code.ReadUint8(ins[ip+1:]))
+			frame.ip += 1
+
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			if compoundOp != 0 {
+				// compound assignments like `xs[0] += 1` combine the current element with the value
+				current := evaluator.EvalIndex(left, index)
+				if current.Type() == object.ErrorObj {
+					err = vm.locate(current.(*object.Error))
+					break
+				}
+				value = evaluator.EvalInfix(binaryOperators[compoundOp], current, value)
+				if value.Type() == object.ErrorObj {
+					err = vm.locate(value.(*object.Error))
+					break
+				}
+			}
+
+			err = vm.pushResult(evaluator.EvalIndexAssignment(left, index, value))
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			condition := vm.pop()
+			if !evaluator.IsTruthy(condition) {
+				frame.ip = pos - 1
+			}
+
+		case code.OpJumpTruthyOrPop, code.OpJumpNotTruthyOrPop:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			if evaluator.IsTruthy(vm.stack[vm.sp-1]) == (op == code.OpJumpTruthyOrPop) {
+				frame.ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpIterInit:
+			var it *iterator
+			it, err = newIterator(vm.pop())
+			if err == nil {
+				err = vm.push(it)
+			} else {
+				err = vm.locate(err)
+			}
+
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			it := vm.stack[vm.sp-1].(*iterator)
+			if next, ok := it.next(); ok {
+				err = vm.push(next)
+			} else {
+				frame.ip = pos - 1
+			}
+
+		case code.OpDefaultMissing:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			pos := int(code.ReadUint16(ins[ip+2:]))
+			frame.ip += 3
+
+			// the default value is only evaluated if no argument was passed
+			if frame.scope.Locals[localIndex] != nil {
+				frame.ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			value := vm.globals[globalIndex]
+			if value == nil {
+				err = vm.newError("identifier not found: %s", vm.globalNames[globalIndex])
+				break
+			}
+			err = vm.push(value)
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			frame.scope.Locals[localIndex] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			err = vm.pushVariable(frame.scope, int(localIndex))
+
+		case code.OpSetOuter:
+			depth := code.ReadUint8(ins[ip+1:])
+			localIndex := code.ReadUint8(ins[ip+2:])
+			frame.ip += 2
+			outerScope(frame.scope, int(depth)).Locals[localIndex] = vm.pop()
+
+		case code.OpGetOuter:
+			depth := code.ReadUint8(ins[ip+1:])
+			localIndex := code.ReadUint8(ins[ip+2:])
+			frame.ip += 2
+			err = vm.pushVariable(outerScope(frame.scope, int(depth)), int(localIndex))
+
+		case code.OpGetName:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.getName(vm.constants[nameIndex].(*object.String).Value)
+
+		case code.OpSetName:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.setName(vm.constants[nameIndex].(*object.String).Value, vm.pop())
+
+		case code.OpEnterScope:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			names := vm.constants[constIndex].(*object.CompiledScope).LocalNames
+			frame.scope = &object.Scope{Names: names, Locals: make([]object.Object, len(names)), Outer: frame.scope}
+
+		case code.OpLeaveScope:
+			frame.scope = frame.scope.Outer
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			fn := vm.constants[constIndex].(*object.CompiledFunction)
+			err = vm.push(&object.Closure{Fn: fn, Scope: frame.scope})
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			if vm.framesIndex > 1 && isTailCall(ins, frame.ip+1) {
+				err = vm.tailCall(int(numArgs))
+			} else {
+				err = vm.callFunction(int(numArgs))
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// a return statement outside of functions ends the program
+			if vm.framesIndex == 1 {
+				vm.lastPopped = returnValue
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer
+			err = vm.push(returnValue)
+
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer
+			err = vm.push(evaluator.NULL)
+
+		default:
+			err = vm.newError("unknown opcode %d", op)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// callFunction calls the closure or builtin below the numArgs arguments on top of the stack
+func (vm *VM) callFunction(numArgs int) *object.Error {
+	basePointer := vm.sp - 1 - numArgs
+	callee := vm.stack[basePointer]
+	args := vm.stack[basePointer+1 : vm.sp]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		fn := callee.Fn
+		if err := checkArity(fn, numArgs); err != nil {
+			return vm.locate(err)
+		}
+
+		if vm.framesIndex > MaxDepth {
+			return vm.newError("%s", vm.recursionError())
+		}
+
+		scope := &object.Scope{Names: fn.LocalNames, Locals: make([]object.Object, fn.NumLocals), Outer: callee.Scope}
+		if numArgs > fn.NumParameters {
+			copy(scope.Locals, args[:fn.NumParameters])
+		} else {
+			copy(scope.Locals, args)
+		}
+
+		// arguments exceeding the parameters are collected in the rest parameter
+		if fn.HasRest {
+			rest := []object.Object{}
+			if numArgs > fn.NumParameters {
+				rest = append(rest, args[fn.NumParameters:]...)
+			}
+			scope.Locals[fn.NumParameters] = &object.Array{Elements: rest}
+		}
+
+		vm.sp = basePointer
+		vm.pushFrame(NewFrame(callee, basePointer, scope))
+		return nil
+
+	case *object.Builtin:
+		argsCopy := make([]object.Object, numArgs)
+		copy(argsCopy, args)
+		vm.sp = basePointer
+
+		return vm.pushResult(callee.Fn(vm, argsCopy...))
+
+	default:
+		return vm.newError("not a function: %s", callee.Type())
+	}
+}
+
+// isTailCall reports whether the call ending at ip is the last thing its function does, i.e. whether only
+// jumps lead from it to returning its result. Jumps back belong to loops, which may do more.
+func isTailCall(ins code.Instructions, ip int) bool {
+	for ip < len(ins) {
+		switch code.Opcode(ins[ip]) {
+		case code.OpJump:
+			target := int(code.ReadUint16(ins[ip+1:]))
+			if target <= ip {
+				return false
+			}
+			ip = target
+		case code.OpReturnValue:
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// tailCall calls the closure below the numArgs arguments on top of the stack in place of the current frame,
+// so tail recursion doesn't grow the frames. Anything else is called like by callFunction.
+func (vm *VM) tailCall(numArgs int) *object.Error {
+	calleeIndex := vm.sp - 1 - numArgs
+	closure, ok := vm.stack[calleeIndex].(*object.Closure)
+	if !ok || checkArity(closure.Fn, numArgs) != nil {
+		// report errors at the call, from the frame making it
+		return vm.callFunction(numArgs)
+	}
+
+	// the callee and its arguments replace the caller, the result is returned to the caller's caller
+	frame := vm.popFrame()
+	copy(vm.stack[frame.basePointer:], vm.stack[calleeIndex:vm.sp])
+	vm.sp = frame.basePointer + 1 + numArgs
+
+	return vm.callFunction(numArgs)
+}
+
+// recursionError returns the error of a call exceeding MaxDepth, with the chain of calls like the evaluator
+func (vm *VM) recursionError() *object.Error {
+	calls := make([]string, 0, vm.framesIndex-1)
+	for _, frame := range vm.frames[1:vm.framesIndex] {
+		calls = append(calls, functionName(frame.cl.Fn))
+	}
+	return evaluator.NewRecursionError(calls)
+}
+
+// functionName returns the name of fn for error messages
+func functionName(fn *object.CompiledFunction) string {
+	if fn.Name == "" {
+		return "anonymous function"
+	}
+	return "`" + fn.Name + "`"
+}
+
+// Call applies fn to args on behalf of a builtin, the VM is the object.Context builtins are called with.
+// A closure is called by running it on top of the frames of the builtin's caller until it returns.
+func (vm *VM) Call(fn object.Object, args ...object.Object) object.Object {
+	sp, depth := vm.sp, vm.framesIndex
+
+	err := vm.push(fn)
+	for _, arg := range args {
+		if err != nil {
+			break
+		}
+		err = vm.push(arg)
+	}
+	if err == nil {
+		err = vm.callFunction(len(args))
+	}
+	if err == nil && vm.framesIndex > depth {
+		err = vm.run(depth)
+	}
+	if err != nil {
+		// the builtin gets the error, the frames of the failed call are dropped
+		vm.sp, vm.framesIndex = sp, depth
+		return err
+	}
+
+	return vm.pop()
+}
+
+// checkArity returns an error if fn can't be called with numArgs arguments
+func checkArity(fn *object.CompiledFunction, numArgs int) *object.Error {
+	required := fn.NumParameters - fn.NumDefaults
+
+	var want string
+	switch {
+	case fn.HasRest:
+		want = fmt.Sprintf("at least %d", required)
+	case required != fn.NumParameters:
+		want = fmt.Sprintf("%d to %d", required, fn.NumParameters)
+	default:
+		want = fmt.Sprintf("%d", required)
+	}
+
+	if numArgs < required || (!fn.HasRest && numArgs > fn.NumParameters) {
+		return evaluator.NewError("wrong number of arguments to %s. got=%d, want=%s", functionName(fn), numArgs, want)
+	}
+
+	return nil
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, *object.Error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, vm.newError("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash, nil
+}
+
+// getName pushes the global or builtin called name, for names the compiler couldn't resolve
+func (vm *VM) getName(name string) *object.Error {
+	if i, ok := vm.globalIndex(name); ok && vm.globals[i] != nil {
+		return vm.push(vm.globals[i])
+	}
+
+	if builtin, ok := evaluator.LookupBuiltin(name); ok {
+		return vm.push(builtin)
+	}
+
+	return vm.newError("identifier not found: %s", name)
+}
+
+// setName assigns to the global called name, for names the compiler couldn't resolve
+func (vm *VM) setName(name string, value object.Object) *object.Error {
+	if i, ok := vm.globalIndex(name); ok && vm.globals[i] != nil {
+		vm.globals[i] = value
+		return nil
+	}
+
+	return vm.newError("assignment to undeclared identifier: %s", name)
+}
+
+// globalIndex returns the index of the global called name
+func (vm *VM) globalIndex(name string) (int, bool) {
+	if vm.globalIndexes == nil {
+		vm.globalIndexes = make(map[string]int, len(vm.globalNames))
+		for i, globalName := range vm.globalNames {
+			vm.globalIndexes[globalName] = i
+		}
+	}
+
+	i, ok := vm.globalIndexes[name]
+	return i, ok
+}
+
+// pushVariable pushes a local variable of scope, which has to be set already
+func (vm *VM) pushVariable(scope *object.Scope, index int) *object.Error {
+	value := scope.Locals[index]
+	if value == nil {
+		return vm.newError("identifier not found: %s", scope.Names[index])
+	}
+	return vm.push(value)
+}
+
+// outerScope returns the scope depth levels above scope
+func outerScope(scope *object.Scope, depth int) *object.Scope {
+	for ; depth > 0; depth-- {
+		scope = scope.Outer
+	}
+	return scope
+}
+
+// pushResult pushes the result of an operation or a builtin, turning error objects into runtime errors
+func (vm *VM) pushResult(result object.Object) *object.Error {
+	if result == nil {
+		return vm.push(evaluator.NULL)
+	}
+	if err, ok := result.(*object.Error); ok {
+		return vm.locate(err)
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if vm.sp >= StackSize {
+		return vm.newError("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) newError(format string, a ...interface{}) *object.Error {
+	return vm.locate(evaluator.NewError(format, a...))
+}
+
+// locate attaches the source position of the current instruction to err, unless it already has one
+func (vm *VM) locate(err *object.Error) *object.Error {
+	if !err.Pos.IsValid() {
+		frame := vm.currentFrame()
+		err.Pos = frame.cl.Fn.SourceMap[frame.start]
+	}
+	return err
+}
@@ -0,0 +1,102 @@
+package vm
+
+import (
+	"testing"
+	"waixg/compiler"
+	"waixg/enginetest"
+	"waixg/interpreter/ast"
+	"waixg/interpreter/lexer"
+	"waixg/interpreter/object"
+	"waixg/interpreter/parser"
+)
+
+// testRun compiles and runs input, returning the value of the last expression statement
+// or the compilation or runtime error.
+func testRun(t *testing.T, input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser has %d errors for %q: %v", len(p.Errors()), input, p.Errors())
+	}
+
+	return runProgram(program)
+}
+
+// runProgram compiles and runs program, returning the value of the last expression statement
+// or the compilation or runtime error.
+func runProgram(program *ast.Program) object.Object {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return err.(*object.Error)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err != nil {
+		return err.(*object.Error)
+	}
+
+	return vm.LastPoppedStackElem()
+}
+
+func TestScenarios(t *testing.T) {
+	enginetest.Run(t, runProgram)
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true;", "ERROR: 1:3: type mismatch: INTEGER + BOOLEAN"},
+		{"let x = 1;\nlet y = -true;", "ERROR: 2:9: unknown operator: -BOOLEAN"},
+		{"let f = fn() {\n  foobar;\n};\nf();", "ERROR: 2:3: identifier not found: foobar"},
+		{`len(1)`, "ERROR: 1:4: argument to `len` not supported, got INTEGER"},
+		{"let x = 1;\nbreak;", "ERROR: 2:1: break outside of loop"},
+		{"map([1], fn(x) {\n  x + true\n});", "ERROR: 2:5: type mismatch: INTEGER + BOOLEAN"},
+		{`sort([1, "a"])`, "ERROR: 1:5: cannot compare STRING and INTEGER in `sort`"},
+	}
+
+	for i, tt := range tests {
+		result := testRun(t, tt.input)
+		errObj, ok := result.(*object.Error)
+		if !ok {
+			t.Errorf("test %d: no error object returned. got=%T (%+v)", i, result, result)
+			continue
+		}
+		if errObj.Inspect() != tt.expected {
+			t.Errorf("test %d: wrong error. expected=%q, got=%q", i, tt.expected, errObj.Inspect())
+		}
+	}
+}
+
+func TestGlobalsAcrossRuns(t *testing.T) {
+	symbolTable := compiler.NewSymbolTable()
+	constants := []object.Object{}
+	globals := []object.Object{}
+
+	inputs := []string{"let a = 1;", "let b = a + 1;", "a + b"}
+	var last object.Object
+	for _, input := range inputs {
+		program := parser.New(lexer.New(input)).ParseProgram()
+
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		machine := NewWithGlobals(bytecode, globals)
+		if err := machine.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		globals = machine.Globals()
+		last = machine.LastPoppedStackElem()
+	}
+
+	result, ok := last.(*object.Integer)
+	if !ok || result.Value != 3 {
+		t.Errorf("wrong result. expected=3, got=%T (%+v)", last, last)
+	}
+}
@@ -0,0 +1,106 @@
+// Package waixg embeds the interpreter of the language into Go programs.
+//
+// An Interpreter keeps the variables of the programs it runs, so values can be passed back and forth
+// between Go and the language:
+//
+//	interp := waixg.New()
+//	_ = interp.Set("names", []string{"a", "b"})
+//	_ = interp.RegisterBuiltin("upper", strings.ToUpper)
+//	result, err := interp.Run(`upper(names[0])`)
+package waixg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"waixg/evaluator"
+	"waixg/interpreter/lexer"
+	"waixg/interpreter/object"
+	"waixg/interpreter/parser"
+)
+
+// Interpreter runs programs with the tree-walking evaluator.
+// The variables defined by a program stay available to later runs.
+// An Interpreter must not be used by multiple goroutines at the same time.
+type Interpreter struct {
+	env *object.Environment
+}
+
+func New() *Interpreter {
+	return &Interpreter{env: object.NewEnvironment()}
+}
+
+// Configure sets the options of the evaluator, like the recursion depth or the budgets of a run.
+func (i *Interpreter) Configure(opts evaluator.Options) {
+	evaluator.Configure(i.env, opts)
+}
+
+// Run parses and evaluates src, returning the value of its last statement.
+// Parser errors are returned joined into one error, a runtime error of the program is returned as an
+// *object.Error.
+func (i *Interpreter) Run(src string) (object.Object, error) {
+	return i.RunContext(context.Background(), src)
+}
+
+// RunContext is like Run, but stops the program once ctx is done.
+func (i *Interpreter) RunContext(ctx context.Context, src string) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.Join(p.Errors()...)
+	}
+
+	result := evaluator.EvalContext(ctx, program, i.env)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj
+	}
+	if result == nil {
+		// e.g. a program ending with a let statement
+		return evaluator.NULL, nil
+	}
+
+	return result, nil
+}
+
+// Set defines the variable name for the programs run later, with value converted like by ToObject.
+func (i *Interpreter) Set(name string, value interface{}) error {
+	obj, err := toObject(value, name)
+	if err != nil {
+		return err
+	}
+
+	i.env.Set(name, obj)
+	return nil
+}
+
+// ErrUndefined is returned by Get for a variable that isn't defined
+var ErrUndefined = errors.New("undefined variable")
+
+// Get returns the value of the variable name converted like by FromObject.
+// It returns an error wrapping ErrUndefined if the variable is not defined.
+func (i *Interpreter) Get(name string) (interface{}, error) {
+	obj, ok := i.env.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUndefined, name)
+	}
+
+	return FromObject(obj)
+}
+
+// RegisterBuiltin makes the Go function fn available as the builtin function name.
+// Unlike a function defined with Set, a builtin can't be assigned to by programs.
+// fn is converted like by ToObject, so it can take and return Go values or objects.
+func (i *Interpreter) RegisterBuiltin(name string, fn interface{}) error {
+	obj, err := toObject(fn, name)
+	if err != nil {
+		return err
+	}
+
+	builtin, ok := obj.(*object.Builtin)
+	if !ok {
+		return fmt.Errorf("builtin %s must be a function, got %T", name, fn)
+	}
+
+	evaluator.DefineBuiltin(i.env, name, builtin)
+	return nil
+}
@@ -0,0 +1,399 @@
+package waixg
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"waixg/evaluator"
+	"waixg/interpreter/object"
+)
+
+func TestRun(t *testing.T) {
+	interp := New()
+
+	if _, err := interp.Run("let add = fn(a, b) { a + b };"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := interp.Run("add(1, 2)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "3" {
+		t.Errorf("wrong result. got=%s, want=3", result.Inspect())
+	}
+
+	result, err = interp.Run("let x = 1;")
+	if err != nil || result != evaluator.NULL {
+		t.Errorf("let statement did not evaluate to null. got=%v (%v)", result, err)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let = 5; let x 1;", "1:5: [PeekTypeMismatch] Expected next token to be IDENT, got = instead\n" +
+			"1:5: [NoPrefixParseFnError] No prefix parse function for = found\n" +
+			"1:16: [PeekTypeMismatch] Expected next token to be =, got INT instead"},
+		{"1 + true", "1:3: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for i, tt := range tests {
+		_, err := New().Run(tt.input)
+		if err == nil {
+			t.Errorf("test %d: expected an error", i)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("test %d: wrong error.\nexpected=%q\ngot=%q", i, tt.expected, err.Error())
+		}
+	}
+
+	_, err := New().Run("1 / 0")
+	var errObj *object.Error
+	if !errors.As(err, &errObj) {
+		t.Errorf("runtime error is not an *object.Error. got=%T", err)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	interp := New()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := interp.RunContext(ctx, "while (true) { }")
+	if !errors.Is(err, evaluator.ErrCanceled) {
+		t.Errorf("run was not canceled. got=%v", err)
+	}
+
+	interp.Configure(evaluator.Options{MaxSteps: 100})
+	_, err = interp.Run("while (true) { }")
+	if !errors.Is(err, evaluator.ErrStepBudgetExceeded) {
+		t.Errorf("step budget was not exceeded. got=%v", err)
+	}
+}
+
+func TestSetAndGet(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		inspect  string
+		expected interface{}
+	}{
+		{nil, "null", nil},
+		{true, "true", true},
+		{42, "42", int64(42)},
+		{uint8(7), "7", int64(7)},
+		{2.5, "2.5", 2.5},
+		{"hello", "hello", "hello"},
+		{[]int{1, 2}, "[1, 2]", []interface{}{int64(1), int64(2)}},
+		{[2]string{"a", "b"}, "[a, b]", []interface{}{"a", "b"}},
+		{map[string]int{"a": 1}, "{a: 1}", map[interface{}]interface{}{"a": int64(1)}},
+		{map[string]int{"b": 2, "c": 3, "a": 1}, "{a: 1, b: 2, c: 3}", map[interface{}]interface{}{"a": int64(1), "b": int64(2), "c": int64(3)}},
+		{map[int]bool{10: true, -1: false, 9: true}, "{-1: false, 9: true, 10: true}", map[interface{}]interface{}{int64(10): true, int64(-1): false, int64(9): true}},
+		{&object.Integer{Value: 3}, "3", int64(3)},
+	}
+
+	for i, tt := range tests {
+		interp := New()
+		if err := interp.Set("x", tt.value); err != nil {
+			t.Errorf("test %d: unexpected error: %s", i, err)
+			continue
+		}
+
+		result, err := interp.Run("x")
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %s", i, err)
+			continue
+		}
+		if result.Inspect() != tt.inspect {
+			t.Errorf("test %d: wrong value. got=%s, want=%s", i, result.Inspect(), tt.inspect)
+		}
+
+		value, err := interp.Get("x")
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, tt.expected) {
+			t.Errorf("test %d: wrong Go value. got=%#v, want=%#v", i, value, tt.expected)
+		}
+	}
+
+	if _, err := New().Get("undefined"); !errors.Is(err, ErrUndefined) {
+		t.Errorf("undefined variable was found. got=%v", err)
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{uint64(1 << 63), "cannot convert 9223372036854775808 to INTEGER"},
+		{struct{}{}, "cannot convert struct {} to an object"},
+		{map[interface{}]int{[1]int{}: 1}, "unusable as hash key: ARRAY"},
+		{func() (int, int, error) { return 0, 0, nil }, "cannot convert func() (int, int, error) to an object: too many results"},
+		{cyclicMap(), "cannot convert map[string]interface {} to an object: it contains itself"},
+		{cyclicSlice(), "cannot convert []interface {} to an object: it contains itself"},
+		{cyclicPointer(), "cannot convert *interface {} to an object: it contains itself"},
+	}
+
+	for i, tt := range tests {
+		err := New().Set("x", tt.value)
+		if err == nil {
+			t.Errorf("test %d: expected an error", i)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("test %d: wrong error. expected=%q, got=%q", i, tt.expected, err.Error())
+		}
+	}
+}
+
+func cyclicMap() map[string]interface{} {
+	m := map[string]interface{}{"a": 1}
+	m["self"] = []interface{}{m}
+	return m
+}
+
+func cyclicSlice() []interface{} {
+	s := []interface{}{1, nil}
+	s[1] = s
+	return s
+}
+
+func cyclicPointer() *interface{} {
+	var v interface{}
+	v = &v
+	return &v
+}
+
+func TestSetSharedValues(t *testing.T) {
+	shared := []int{1, 2}
+	s := []interface{}{1, nil, shared, shared}
+	// s[:1] shares the elements of s but isn't s
+	s[1] = s[:1]
+
+	interp := New()
+	if err := interp.Set("x", s); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	result, err := interp.Run("x")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "[1, [1], [1, 2], [1, 2]]" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestGetSelfReferences(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = [1]; x[0] = x;", "cannot convert ARRAY: it contains itself"},
+		{`let x = {}; x["k"] = [x];`, "cannot convert HASH: it contains itself"},
+	}
+
+	for i, tt := range tests {
+		interp := New()
+		if _, err := interp.Run(tt.input); err != nil {
+			t.Fatalf("test %d: unexpected error: %s", i, err)
+		}
+
+		_, err := interp.Get("x")
+		if err == nil {
+			t.Errorf("test %d: expected an error", i)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("test %d: wrong error. expected=%q, got=%q", i, tt.expected, err.Error())
+		}
+	}
+
+	// a value used twice doesn't contain itself
+	interp := New()
+	if _, err := interp.Run("let y = [1]; let x = [y, {\"k\": y}];"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	value, err := interp.Get("x")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []interface{}{[]interface{}{int64(1)}, map[interface{}]interface{}{"k": []interface{}{int64(1)}}}
+	if !reflect.DeepEqual(value, expected) {
+		t.Errorf("wrong Go value. got=%#v, want=%#v", value, expected)
+	}
+}
+
+var errNegative = errors.New("negative number")
+
+func TestRegisterBuiltin(t *testing.T) {
+	interp := New()
+
+	builtins := map[string]interface{}{
+		"upper": strings.ToUpper,
+		"sum": func(xs ...int) int {
+			total := 0
+			for _, x := range xs {
+				total += x
+			}
+			return total
+		},
+		"sqrt": func(x float64) (float64, error) {
+			if x < 0 {
+				return 0, errNegative
+			}
+			return x / 2, nil
+		},
+		"keys": func(h map[string]interface{}) []string {
+			var keys []string
+			for k := range h {
+				keys = append(keys, k)
+			}
+			return keys
+		},
+		"kind": func(obj object.Object) string { return string(obj.Type()) },
+		"noop": func() {},
+		"raw":  func(args ...object.Object) object.Object { return &object.Integer{Value: int64(len(args))} },
+		"boom": func(s string) string { panic("boom: " + s) },
+		"at":   func(xs []int, i int) int { return xs[i] },
+		"explode": func(ctx object.Context, args ...object.Object) object.Object {
+			var m map[string]int
+			m["x"] = 1
+			return nil
+		},
+	}
+	for name, fn := range builtins {
+		if err := interp.RegisterBuiltin(name, fn); err != nil {
+			t.Fatalf("cannot register %s: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`upper("abc")`, "ABC"},
+		{`upper("abc") + "!"`, "ABC!"},
+		{"sum()", "0"},
+		{"sum(1, 2, 3)", "6"},
+		{"sqrt(8)", "4.0"},
+		{`keys({"a": 1})`, "[a]"},
+		{"kind(fn() {})", "FUNCTION"},
+		{"noop()", "null"},
+		{"raw(1, 2)", "2"},
+		{`let noop = fn(s) { s }; noop("abc")`, "abc"},
+	}
+
+	for i, tt := range tests {
+		result, err := interp.Run(tt.input)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %s", i, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("test %d: wrong result. got=%s, want=%s", i, result.Inspect(), tt.expected)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"upper(1)", "1:6: argument to `upper` not supported, got INTEGER"},
+		{"upper()", "1:6: wrong number of arguments. got=0, want=1"},
+		{`sum(1, "2")`, "1:4: argument to `sum` not supported, got STRING"},
+		{"sqrt(-1)", "1:5: negative number"},
+		{"upper = 1", "1:7: assignment to undeclared identifier: upper"},
+		{`boom("x")`, "1:5: panic in `boom`: boom: x"},
+		{"at([1], 5)", "1:3: panic in `at`: runtime error: index out of range [5] with length 1"},
+		{"explode()", "1:8: panic in `explode`: assignment to entry in nil map"},
+		{`map([1], fn(x) { boom("y") })`, "1:22: panic in `boom`: boom: y"},
+	}
+
+	for i, tt := range errorTests {
+		_, err := interp.Run(tt.input)
+		if err == nil {
+			t.Errorf("test %d: expected an error", i)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("test %d: wrong error. expected=%q, got=%q", i, tt.expected, err.Error())
+		}
+	}
+
+	if _, err := interp.Run("sqrt(-1)"); !errors.Is(err, errNegative) {
+		t.Errorf("error of builtin is not kept. got=%v", err)
+	}
+
+	if err := interp.RegisterBuiltin("answer", 42); err == nil {
+		t.Errorf("registered a builtin that is not a function")
+	}
+}
+
+func TestBuiltinCallingFunctions(t *testing.T) {
+	interp := New()
+
+	builtins := map[string]interface{}{
+		"twice": func(ctx object.Context, fn object.Object, x int) object.Object {
+			once := ctx.Call(fn, &object.Integer{Value: int64(x)})
+			if _, ok := once.(*object.Error); ok {
+				return once
+			}
+			return ctx.Call(fn, once)
+		},
+		"apply": func(ctx object.Context, args ...object.Object) object.Object {
+			return ctx.Call(args[0], args[1:]...)
+		},
+	}
+	for name, fn := range builtins {
+		if err := interp.RegisterBuiltin(name, fn); err != nil {
+			t.Fatalf("cannot register %s: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"twice(fn(x) { x * 3 }, 2)", "18"},
+		{"apply(fn(a, b) { a - b }, 5, 3)", "2"},
+		{`apply(upper, "a")`, "A"},
+		{"map([1, 2], fn(x) { twice(fn(y) { y + x }, 0) })", "[2, 4]"},
+	}
+
+	for i, tt := range tests {
+		result, err := interp.Run(tt.input)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %s", i, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("test %d: wrong result. got=%s, want=%s", i, result.Inspect(), tt.expected)
+		}
+	}
+
+	_, err := interp.Run("twice(fn(x) { x + true }, 1)")
+	if err == nil || err.Error() != "1:17: type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("error of the function was not passed on. got=%v", err)
+	}
+}
+
+func TestBuiltinsArePerInterpreter(t *testing.T) {
+	first := New()
+	if err := first.RegisterBuiltin("answer", func() int { return 42 }); err != nil {
+		t.Fatalf("cannot register builtin: %s", err)
+	}
+
+	if _, err := New().Run("answer()"); err == nil || err.Error() != "1:1: identifier not found: answer" {
+		t.Errorf("builtin leaked into another interpreter. got=%v", err)
+	}
+}